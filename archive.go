@@ -3,33 +3,72 @@ package renpyarchivetool
 import (
 	"bufio"
 	"bytes"
-	"compress/zlib"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
-	"strconv"
+	"sort"
 	"strings"
-
-	"github.com/nlpodyssey/gopickle/pickle"
-	"github.com/nlpodyssey/gopickle/types"
+	"time"
 )
 
+// RenPyArchive is a Ren'Py archive opened for reading. Once loaded, its read
+// methods only use positioned reads on the underlying reader and are safe for
+// concurrent use by multiple goroutines. Load must not be called concurrently
+// with any other method.
 type RenPyArchive struct {
 	file     string
-	handle   *os.File
+	handle   io.ReaderAt
+	closer   io.Closer
+	size     int64
 	metadata string
+	modTime  time.Time
+	scheme   Scheme
 	version  RPAVersion
 	key      int64
 
+	headerSize  int64
+	indexOffset int64
+
 	indexes map[string]*Index
 }
 
+// Index describes where a file is stored in the archive.
 type Index struct {
+	// Offset is where the data of the first segment is stored.
+	Offset int64
+	// Length is the size of the file, including the prefixes of all segments.
+	Length int64
+	// Segments are concatenated to form the file.
+	Segments []Segment
+}
+
+// Segment is a stored chunk of a file: Prefix, which is kept in the index,
+// followed by Length bytes stored at Offset.
+type Segment struct {
 	Offset int64
 	Length int64
 	Prefix []byte
 }
 
+// NewIndex creates an Index for a file made of the given segments.
+func NewIndex(segments []Segment) *Index {
+	index := &Index{
+		Segments: segments,
+	}
+
+	for _, segment := range segments {
+		index.Length += int64(len(segment.Prefix)) + segment.Length
+	}
+
+	if len(segments) > 0 {
+		index.Offset = segments[0].Offset
+	}
+
+	return index
+}
+
 func Load(fileName string) (*RenPyArchive, error) {
 	rp := &RenPyArchive{}
 	if err := rp.Load(fileName); err != nil {
@@ -39,64 +78,202 @@ func Load(fileName string) (*RenPyArchive, error) {
 	return rp, nil
 }
 
+// NewReader reads an archive of the given size from r, which allows archives
+// to be read from memory, from inside other archives or from remote sources.
+func NewReader(r io.ReaderAt, size int64) (*RenPyArchive, error) {
+	rp := &RenPyArchive{}
+	if err := rp.init(r, size); err != nil {
+		return nil, err
+	}
+
+	return rp, nil
+}
+
 func (rp *RenPyArchive) Load(fileName string) error {
-	var err error
 	rp.file = fileName
-	if rp.handle != nil {
-		if err := rp.handle.Close(); err != nil {
+	if rp.closer != nil {
+		if err := rp.closer.Close(); err != nil {
 			return fmt.Errorf("failed to close file. %v", err)
 		}
+		rp.closer = nil
+	}
+
+	// legacy archives can be loaded through either their data or index file
+	dataFileName := fileName
+	if isLegacyIndexFile(fileName) {
+		dataFileName = legacyDataFileName(fileName)
 	}
 
-	rp.handle, err = os.Open(fileName)
+	handle, err := os.Open(dataFileName)
 	if err != nil {
-		return fmt.Errorf("failed to open file: %s. %v", fileName, err)
+		return fmt.Errorf("failed to open file: %s. %w", dataFileName, err)
+	}
+
+	info, err := handle.Stat()
+	if err != nil {
+		handle.Close()
+		return fmt.Errorf("failed to stat file: %s. %w", dataFileName, err)
+	}
+
+	if dataFileName != fileName {
+		err = rp.loadLegacy(handle, info.Size(), fileName)
+	} else {
+		err = rp.init(handle, info.Size())
+		if errors.Is(err, ErrNotArchive) {
+			if _, statErr := os.Stat(LegacyIndexFileName(fileName)); statErr == nil {
+				err = rp.loadLegacy(handle, info.Size(), LegacyIndexFileName(fileName))
+			}
+		}
+	}
+	if err != nil {
+		handle.Close()
+		return err
+	}
+	rp.closer = handle
+	rp.modTime = info.ModTime()
+
+	return nil
+}
+
+// Close releases the file opened by Load. Readers passed to NewReader are
+// owned by the caller and are left open.
+func (rp *RenPyArchive) Close() error {
+	if rp.closer == nil {
+		return nil
+	}
+
+	err := rp.closer.Close()
+	rp.closer = nil
+
+	return err
+}
+
+var _ io.Closer = (*RenPyArchive)(nil)
+
+func (rp *RenPyArchive) init(r io.ReaderAt, size int64) error {
+	rp.handle = r
+	rp.size = size
+
+	header := readHeader(r, size)
+	rp.metadata = string(header)
+
+	scheme := detectScheme(header)
+	if scheme == nil {
+		magic := string(bytes.SplitN(header, []byte(" "), 2)[0])
+		for _, known := range []string{RPA2Magic, RPA3Magic, RPA32Magic, RPA4Magic, ALT1Magic} {
+			if magic == strings.TrimSpace(known) {
+				return fmt.Errorf("failed to get version. %w: %s has no fields", ErrInvalidHeader, magic)
+			}
+		}
+		if strings.HasPrefix(magic, "RPA-") {
+			return fmt.Errorf("failed to get version. %w: %s", ErrUnsupportedVersion, magic)
+		}
+		return fmt.Errorf("failed to get version. %w", ErrNotArchive)
 	}
 
-	if err := rp.getVersion(); err != nil {
-		return fmt.Errorf("failed to get version. %v", err)
+	decoded, err := scheme.DecodeIndex(header, r, size)
+	if err != nil {
+		return fmt.Errorf("failed to extract indexes. %w", err)
 	}
 
-	if err := rp.extractIndexes(); err != nil {
-		return fmt.Errorf("failed to extract indexes. %v", err)
+	if err := checkSegments(decoded.Indexes, int64(len(header))+1, size); err != nil {
+		return fmt.Errorf("failed to extract indexes. %w", err)
 	}
 
+	rp.scheme = scheme
+	rp.version = decoded.Version
+	rp.key = decoded.Key
+	rp.headerSize = int64(len(header)) + 1
+	rp.indexOffset = decoded.Offset
+	rp.indexes = decoded.Indexes
+
 	return nil
 }
 
+// readHeader returns the first line of the archive, without the newline.
+func readHeader(r io.ReaderAt, size int64) []byte {
+	scanner := bufio.NewScanner(io.NewSectionReader(r, 0, size))
+	scanner.Scan() // read 1 line into the scanner
+
+	return scanner.Bytes()
+}
+
+// ModTime returns the modification time of the file the archive was loaded
+// from, zero for archives read with NewReader.
+func (rp *RenPyArchive) ModTime() time.Time {
+	return rp.modTime
+}
+
+// Scheme returns the scheme the archive was decoded with, or nil for RPA-1.0
+// archives.
+func (rp *RenPyArchive) Scheme() Scheme {
+	return rp.scheme
+}
+
 const (
+	// RPA1Magic is only used to name RPA-1.0 archives, which have no header.
+	RPA1Magic  = "RPA-1.0 "
 	RPA2Magic  = "RPA-2.0 "
 	RPA3Magic  = "RPA-3.0 "
 	RPA32Magic = "RPA-3.2 "
+	RPA4Magic  = "RPA-4.0 "
+	ALT1Magic  = "ALT-1.0 "
 )
 
 type RPAVersion int
 
 const (
 	RPAVersionUnknown RPAVersion = iota
+	RPAVersion1
 	RPAVersion2
 	RPAVersion3
 	RPAVersion32
+	RPAVersion4
+	RPAVersionALT1
 )
 
 func (r RPAVersion) String() string {
 	switch r {
+	case RPAVersion1:
+		return RPA1Magic
 	case RPAVersion2:
 		return RPA2Magic
 	case RPAVersion3:
 		return RPA3Magic
 	case RPAVersion32:
 		return RPA32Magic
+	case RPAVersion4:
+		return RPA4Magic
+	case RPAVersionALT1:
+		return ALT1Magic
 	}
 
 	return "<unknown>"
 }
 
+// ParseVersion parses a version number such as "3.0" or a header magic such
+// as "RPA-3.0" into an RPAVersion.
+func ParseVersion(s string) (RPAVersion, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RPA-")
+	switch s {
+	case "2.0", "2":
+		return RPAVersion2, nil
+	case "3.0", "3":
+		return RPAVersion3, nil
+	case "3.2":
+		return RPAVersion32, nil
+	}
+
+	return RPAVersionUnknown, fmt.Errorf("%w: %s", ErrUnsupportedVersion, s)
+}
+
+// FileNames returns the names of all files in the archive, sorted.
 func (rp *RenPyArchive) FileNames() []string {
-	out := make([]string, 0)
+	out := make([]string, 0, len(rp.indexes))
 	for fileName := range rp.indexes {
 		out = append(out, fileName)
 	}
+	sort.Strings(out)
 
 	return out
 }
@@ -105,173 +282,33 @@ func (rp *RenPyArchive) Indexes() map[string]*Index {
 	return rp.indexes
 }
 
-func (rp *RenPyArchive) Read(filename string) ([]byte, error) {
-
+// Open returns a reader for the given file. The returned File reads from the
+// archive on demand and supports seeking and random access.
+func (rp *RenPyArchive) Open(filename string) (*File, error) {
 	indexData, ok := rp.indexes[filename]
 	if !ok {
-		return nil, fmt.Errorf("file %s not found in archive", filename)
+		return nil, &fs.PathError{Op: "open", Path: filename, Err: ErrNotExist}
 	}
 
-	_, err := rp.handle.Seek(int64(indexData.Offset), 0)
+	return newFile(rp.handle, filename, indexData), nil
+}
+
+func (rp *RenPyArchive) Read(filename string) ([]byte, error) {
+	f, err := rp.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	buf := make([]byte, indexData.Length)
+	buf := make([]byte, f.Size())
 
-	numRead, err := rp.handle.Read(buf)
-	if err != nil {
+	numRead, err := f.ReadAt(buf, 0)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 		return nil, err
 	}
 
-	if int64(numRead) != indexData.Length {
-		return nil, fmt.Errorf("didn't read full file. wanted to read: %d actually read: %d", indexData.Length, numRead)
+	if int64(numRead) != f.Size() {
+		return nil, fmt.Errorf("%w: didn't read full file %s. wanted to read: %d actually read: %d", ErrTruncated, filename, f.Size(), numRead)
 	}
 
 	return buf, nil
 }
-
-func (rp *RenPyArchive) getVersion() error {
-
-	_, err := rp.handle.Seek(0, 0)
-	if err != nil {
-		return err
-	}
-
-	scanner := bufio.NewScanner(rp.handle)
-	scanner.Scan() // read 1 line into the scanner
-	rp.metadata = scanner.Text()
-
-	switch {
-	case strings.HasPrefix(rp.metadata, RPA32Magic):
-		rp.version = RPAVersion32
-		return nil
-	case strings.HasPrefix(rp.metadata, RPA3Magic):
-		rp.version = RPAVersion3
-		return nil
-	case strings.HasPrefix(rp.metadata, RPA2Magic):
-		rp.version = RPAVersion2
-		return nil
-	}
-
-	return fmt.Errorf("the given file is not a valid Ren'Py archive, or an unsupported version")
-}
-
-func (rp *RenPyArchive) extractIndexes() error {
-	vals := strings.Split(rp.metadata, " ")
-	if len(vals) < 2 {
-		return fmt.Errorf("invalid header format")
-	}
-	offset, err := strconv.ParseInt(vals[1], 16, 64)
-	if err != nil {
-		return nil
-	}
-
-	rp.key = 0
-
-	if rp.version == RPAVersion3 {
-		if len(vals) < 3 {
-			return fmt.Errorf("invalid header format")
-		}
-
-		for i, subKeyString := range vals[2:] {
-			subKey, err := strconv.ParseInt(subKeyString, 16, 64)
-			if err != nil {
-				return fmt.Errorf("failed to parse subkey %d. %v", i, err)
-			}
-			rp.key ^= int64(subKey)
-		}
-	} else if rp.version == RPAVersion32 {
-		if len(vals) < 4 {
-			return fmt.Errorf("invalid header format")
-		}
-
-		for i, subKeyString := range vals[3:] {
-			subKey, err := strconv.ParseInt(subKeyString, 16, 64)
-			if err != nil {
-				return fmt.Errorf("failed to parse subkey %d. %v", i, err)
-			}
-			rp.key ^= int64(subKey)
-		}
-	}
-
-	_, err = rp.handle.Seek(offset, 0)
-	if err != nil {
-		return fmt.Errorf("failed to seek to indexes. %v", err)
-	}
-
-	buf, err := io.ReadAll(rp.handle)
-	if err != nil {
-		return err
-	}
-
-	zlibReader, err := zlib.NewReader(bytes.NewReader(buf))
-	if err != nil {
-		return err
-	}
-	defer zlibReader.Close()
-
-	unpickler := pickle.NewUnpickler(zlibReader)
-	unpickled, err := unpickler.Load()
-	if err != nil {
-		return err
-	}
-
-	obfuscatedIndexs, ok := unpickled.(*types.Dict)
-	if !ok {
-		return fmt.Errorf("unpicked data not *types.Dict. was: %T", unpickled)
-	}
-
-	indexes := make(map[string]*Index)
-
-	for _, keyInterface := range obfuscatedIndexs.Keys() {
-		key, ok := keyInterface.(string)
-		if !ok {
-			return fmt.Errorf("key: %s wasn't a string. it's a %T", keyInterface, keyInterface)
-		}
-
-		interfaceValue, ok := obfuscatedIndexs.Get(key)
-		if !ok {
-			return fmt.Errorf("key: %s didn't exist in dict?", key)
-		}
-		value, ok := interfaceValue.(*types.List)
-		if !ok {
-			return fmt.Errorf("value for key: %s wasn't *types.List. was: %T", key, interfaceValue)
-		}
-
-		indexData, ok := value.Get(0).(*types.Tuple)
-		if !ok {
-			return fmt.Errorf("value.Get(0) for key: %s wasn't *types.Tuple. was: %T", key, value.Get(0))
-		}
-
-		indexOffset, ok := indexData.Get(0).(int)
-		if !ok {
-			return fmt.Errorf("indexOffset for key: %s wasn't int. was: %T", key, indexData.Get(0))
-		}
-		length, ok := indexData.Get(1).(int)
-		if !ok {
-			return fmt.Errorf("length for key: %s wasn't int. was: %T", key, indexData.Get(1))
-		}
-
-		indexes[key] = &Index{
-			Offset: int64(indexOffset) ^ rp.key,
-			Length: int64(length) ^ rp.key,
-		}
-
-		if indexData.Len() > 2 {
-			prefix, ok := indexData.Get(2).(string)
-			if !ok {
-				prefixBytes, ok := indexData.Get(2).([]byte)
-				if !ok {
-					return fmt.Errorf("prefix not not a string or []byte. %T", indexData.Get(2))
-				}
-				prefix = string(prefixBytes)
-			}
-			indexes[key].Prefix = []byte(prefix)
-		}
-	}
-
-	rp.indexes = indexes
-
-	return nil
-}
@@ -0,0 +1,401 @@
+package renpyarchivetool
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+)
+
+// memFile is an in-memory io.WriteSeeker and io.ReaderAt to build archives
+// in tests.
+type memFile struct {
+	data []byte
+	off  int64
+}
+
+func (m *memFile) Write(p []byte) (int, error) {
+	if end := m.off + int64(len(p)); end > int64(len(m.data)) {
+		m.data = append(m.data, make([]byte, end-int64(len(m.data)))...)
+	}
+	copy(m.data[m.off:], p)
+	m.off += int64(len(p))
+
+	return len(p), nil
+}
+
+func (m *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += m.off
+	case io.SeekEnd:
+		offset += int64(len(m.data))
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+	m.off = offset
+
+	return offset, nil
+}
+
+func (m *memFile) ReadAt(p []byte, off int64) (int, error) {
+	return bytes.NewReader(m.data).ReadAt(p, off)
+}
+
+// buildArchive writes files to a new in-memory archive, storing up to
+// prefixLength bytes of each file in the index.
+func buildArchive(t testing.TB, version RPAVersion, key int64, files map[string]string, prefixLength int) *memFile {
+	t.Helper()
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := &memFile{}
+	w, err := NewWriter(out, version, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range names {
+		if err := w.AddFilePrefixed(name, strings.NewReader(files[name]), prefixLength); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return out
+}
+
+// openArchive builds an archive like buildArchive and reads it back.
+func openArchive(t testing.TB, version RPAVersion, key int64, files map[string]string, prefixLength int) *RenPyArchive {
+	t.Helper()
+
+	out := buildArchive(t, version, key, files, prefixLength)
+	archive, err := NewReader(out, int64(len(out.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return archive
+}
+
+func checkContents(t *testing.T, archive *RenPyArchive, files map[string]string) {
+	t.Helper()
+
+	if got := len(archive.FileNames()); got != len(files) {
+		t.Errorf("archive has %d files, want %d", got, len(files))
+	}
+
+	for name, want := range files {
+		data, err := archive.Read(name)
+		if err != nil {
+			t.Errorf("Read(%q): %v", name, err)
+			continue
+		}
+		if string(data) != want {
+			t.Errorf("Read(%q) = %q, want %q", name, data, want)
+		}
+
+		f, err := archive.Open(name)
+		if err != nil {
+			t.Errorf("Open(%q): %v", name, err)
+			continue
+		}
+		if err := iotest.TestReader(f, []byte(want)); err != nil {
+			t.Errorf("Open(%q): %v", name, err)
+		}
+	}
+}
+
+var testFiles = map[string]string{
+	"empty":                "",
+	"a.rpy":                "a",
+	"script.rpy":           "label start:\n    \"Hello, world.\"\n",
+	"images/bg/room.png":   strings.Repeat("\x89PNG\r\n\x1a\n", 100),
+	"audio/music/main.ogg": strings.Repeat("OggS", 1000),
+}
+
+func TestPrefixedRoundTrip(t *testing.T) {
+	tests := []struct {
+		version RPAVersion
+		key     int64
+	}{
+		{RPAVersion2, 0},
+		{RPAVersion3, 0},
+		{RPAVersion3, 0x42424242},
+		{RPAVersion3, 0xffffffff},
+		{RPAVersion32, 0xdeadbeef},
+	}
+
+	for _, tt := range tests {
+		for _, prefixLength := range []int{0, 1, 4, 50, 10000} {
+			t.Run(fmt.Sprintf("%s/%x/%d", strings.TrimSpace(tt.version.String()), tt.key, prefixLength), func(t *testing.T) {
+				archive := openArchive(t, tt.version, tt.key, testFiles, prefixLength)
+
+				if archive.version != tt.version || archive.key != tt.key {
+					t.Errorf("read %s with key %x, want %s with key %x", archive.version, archive.key, tt.version, tt.key)
+				}
+
+				for name, want := range testFiles {
+					index := archive.Indexes()[name]
+					if index.Length != int64(len(want)) {
+						t.Errorf("%s: Length = %d, want %d", name, index.Length, len(want))
+					}
+
+					wantPrefix := prefixLength
+					if wantPrefix > len(want) {
+						wantPrefix = len(want)
+					}
+					if got := len(index.Segments[0].Prefix); got != wantPrefix {
+						t.Errorf("%s: prefix length = %d, want %d", name, got, wantPrefix)
+					}
+				}
+
+				checkContents(t, archive, testFiles)
+			})
+		}
+	}
+}
+
+func TestMultiSegment(t *testing.T) {
+	const key = 0x1234
+
+	out := &memFile{}
+	header := formatHeader(RPAVersion3, 0, key)
+	io.WriteString(out, header)
+
+	dataOffset := int64(len(header))
+	io.WriteString(out, "worldXX!!")
+
+	indexes := map[string]*Index{
+		"greeting.txt": NewIndex([]Segment{
+			{Offset: dataOffset, Length: 5, Prefix: []byte("hello ")},
+			{Offset: dataOffset + 7, Length: 2, Prefix: []byte(" ")},
+		}),
+		"prefix-only.txt": NewIndex([]Segment{
+			{Offset: dataOffset, Length: 0, Prefix: []byte("only")},
+			{Offset: dataOffset, Length: 0, Prefix: []byte(" prefixes")},
+		}),
+		"shared.txt": NewIndex([]Segment{
+			{Offset: dataOffset, Length: 5},
+		}),
+	}
+	names := []string{"greeting.txt", "prefix-only.txt", "shared.txt"}
+
+	index, err := compressIndex(names, indexes, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexOffset := int64(len(out.data))
+	out.Write(index)
+	out.Seek(0, io.SeekStart)
+	io.WriteString(out, formatHeader(RPAVersion3, indexOffset, key))
+
+	archive, err := NewReader(out, int64(len(out.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(archive.Indexes()["greeting.txt"].Segments); got != 2 {
+		t.Errorf("greeting.txt has %d segments, want 2", got)
+	}
+
+	checkContents(t, archive, map[string]string{
+		"greeting.txt":    "hello world !!",
+		"prefix-only.txt": "only prefixes",
+		"shared.txt":      "world",
+	})
+}
+
+// TestConcurrentReads reads all files from many goroutines at once, run it
+// with -race to check that reads don't share state.
+func TestConcurrentReads(t *testing.T) {
+	files := make(map[string]string)
+	for i := 0; i < 200; i++ {
+		files[fmt.Sprintf("dir%d/file%d.txt", i%7, i)] = strings.Repeat(fmt.Sprintf("%d,", i), i)
+	}
+
+	archive := openArchive(t, RPAVersion3, 0xcafe, files, 3)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+
+			for name, want := range files {
+				data, err := archive.Read(name)
+				if err != nil || string(data) != want {
+					errs <- fmt.Errorf("Read(%q) = %q, %v", name, data, err)
+					return
+				}
+
+				f, err := archive.Open(name)
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				// every goroutine reads a different part of the file
+				off := int64(0)
+				if len(want) > 0 {
+					off = int64(g % len(want))
+				}
+				buf := make([]byte, len(want)-int(off))
+				if _, err := f.ReadAt(buf, off); err != nil && err != io.EOF {
+					errs <- fmt.Errorf("ReadAt(%q, %d): %v", name, off, err)
+					return
+				}
+				if string(buf) != want[off:] {
+					errs <- fmt.Errorf("ReadAt(%q, %d) = %q", name, off, buf)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func entryNames(entries []Entry) []string {
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+
+	return names
+}
+
+func TestEntriesDir(t *testing.T) {
+	archive := openArchive(t, RPAVersion3, 0x42, map[string]string{
+		"a.rpy":              "a",
+		"images/bg/room.png": "room",
+		"images/eileen.png":  "eileen",
+		"imagesx/other.png":  "other",
+	}, 0)
+
+	all := []string{"a.rpy", "images/bg/room.png", "images/eileen.png", "imagesx/other.png"}
+	tests := []struct {
+		dir  string
+		want []string
+	}{
+		{"", all},
+		{".", all},
+		{"/", all},
+		{"images", []string{"images/bg/room.png", "images/eileen.png"}},
+		{"images/", []string{"images/bg/room.png", "images/eileen.png"}},
+		{"/images/", []string{"images/bg/room.png", "images/eileen.png"}},
+		{"images/bg", []string{"images/bg/room.png"}},
+		{"image", []string{}},
+		{"a.rpy", []string{}},
+	}
+
+	for _, tt := range tests {
+		got := entryNames(archive.Entries(WalkOptions{Dir: tt.dir}))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Entries with Dir %q = %v, want %v", tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestEntriesOrder(t *testing.T) {
+	const header = "RPA-3.0 %016x 00000042\n"
+	h := int64(len(fmt.Sprintf(header, 0)))
+
+	out := craftArchive(t, header, "ccbbaa", map[string]*Index{
+		"a": NewIndex([]Segment{{Offset: h + 4, Length: 2}}),
+		"b": NewIndex([]Segment{{Offset: h + 2, Length: 2}}),
+		"c": NewIndex([]Segment{{Offset: h, Length: 2}}),
+		// same offset as c, ordered by name after it
+		"d": NewIndex([]Segment{{Offset: h, Length: 1}}),
+	}, 0x42)
+	archive, err := NewReader(out, int64(len(out.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := entryNames(archive.Entries(WalkOptions{})); !reflect.DeepEqual(got, []string{"a", "b", "c", "d"}) {
+		t.Errorf("Entries = %v, want name order", got)
+	}
+	if got := entryNames(archive.Entries(WalkOptions{ByOffset: true})); !reflect.DeepEqual(got, []string{"c", "d", "b", "a"}) {
+		t.Errorf("Entries by offset = %v, want c d b a", got)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	archive := openArchive(t, RPAVersion3, 0x42, testFiles, 0)
+
+	visited := make([]string, 0)
+	err := archive.Walk(WalkOptions{}, func(name string, index *Index) error {
+		visited = append(visited, name)
+		if len(visited) == 2 {
+			return StopWalk
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Walk stopped with StopWalk = %v, want nil", err)
+	}
+	if !reflect.DeepEqual(visited, []string{"a.rpy", "audio/music/main.ogg"}) {
+		t.Errorf("visited %v before stopping", visited)
+	}
+
+	walkErr := errors.New("walk error")
+	visited = visited[:0]
+	err = archive.Walk(WalkOptions{Dir: "images"}, func(name string, index *Index) error {
+		visited = append(visited, name)
+		return walkErr
+	})
+	if err != walkErr || len(visited) != 1 {
+		t.Errorf("Walk = %v after visiting %v, want the error after one file", err, visited)
+	}
+}
+
+func TestWriterFailedFile(t *testing.T) {
+	out := &memFile{}
+	w, err := NewWriter(out, RPAVersion3, 0x42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.AddFile("first.txt", strings.NewReader("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	broken := io.MultiReader(strings.NewReader("partial data"), iotest.ErrReader(errors.New("broken")))
+	if err := w.AddFilePrefixed("broken.txt", broken, 2); err == nil {
+		t.Fatal("adding a failing file succeeded")
+	}
+
+	if err := w.AddFile("last.txt", strings.NewReader("last")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := NewReader(out, int64(len(out.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkContents(t, archive, map[string]string{"first.txt": "first", "last.txt": "last"})
+}
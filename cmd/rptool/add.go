@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tw1nk/renpyarchivetool"
+)
+
+var addCmd *cobra.Command
+
+func init() {
+	addCmd = &cobra.Command{
+		Use:   "add <archive.rpa> <file or folder>...",
+		Short: "Add or replace files in a Ren'Py archive",
+		RunE:  add,
+		Args:  cobra.MinimumNArgs(2),
+	}
+
+	addCmd.Flags().StringP("root", "r", ".", "folder the names in the archive are relative to")
+}
+
+func add(cmd *cobra.Command, args []string) error {
+	root, err := cmd.Flags().GetString("root")
+	if err != nil {
+		return err
+	}
+
+	archiveInfo, err := os.Stat(args[0])
+	if err != nil {
+		return err
+	}
+
+	editor, err := renpyarchivetool.OpenEditor(args[0])
+	if err != nil {
+		return err
+	}
+	defer editor.Close()
+
+	for _, path := range args[1:] {
+		err := filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !d.Type().IsRegular() {
+				return nil
+			}
+
+			// adding the archive to itself would never stop growing it
+			if info, err := d.Info(); err == nil && os.SameFile(info, archiveInfo) {
+				return nil
+			}
+
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+				return fmt.Errorf("%s is not inside the root folder %s", path, root)
+			}
+			name := filepath.ToSlash(rel)
+
+			if editor.Has(name) {
+				log.Printf("Replacing %s", name)
+			} else {
+				log.Printf("Adding %s", name)
+			}
+
+			return addFile(editor, path, name)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := editor.Commit(); err != nil {
+		return err
+	}
+
+	return editor.Close()
+}
+
+func addFile(editor *renpyarchivetool.Editor, path string, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return editor.Add(name, f)
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/tw1nk/renpyarchivetool"
+)
+
+var compactCmd = &cobra.Command{
+	Use:   "compact <archive.rpa>",
+	Short: "Rewrite a Ren'Py archive without unused space",
+	RunE:  compact,
+	Args:  cobra.ExactArgs(1),
+}
+
+func compact(cmd *cobra.Command, args []string) error {
+	return renpyarchivetool.Compact(args[0])
+}
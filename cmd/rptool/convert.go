@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/tw1nk/renpyarchivetool"
+)
+
+var convertCmd *cobra.Command
+
+func init() {
+	convertCmd = &cobra.Command{
+		Use:   "convert <archive.rpa> <output.rpa>",
+		Short: "Convert a Ren'Py archive to another version or key",
+		RunE:  convert,
+		Args:  cobra.ExactArgs(2),
+	}
+
+	convertCmd.Flags().String("to-version", "3.0", "archive version to convert to (2.0, 3.0 or 3.2)")
+	convertCmd.Flags().StringP("key", "k", "random", "obfuscation key as hex, or \"random\"")
+}
+
+func convert(cmd *cobra.Command, args []string) error {
+	versionString, err := cmd.Flags().GetString("to-version")
+	if err != nil {
+		return err
+	}
+
+	version, err := renpyarchivetool.ParseVersion(versionString)
+	if err != nil {
+		return err
+	}
+
+	keyString, err := cmd.Flags().GetString("key")
+	if err != nil {
+		return err
+	}
+
+	key, err := parseKey(keyString, cmd.Flags().Changed("key"), version)
+	if err != nil {
+		return err
+	}
+
+	inputPath, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+
+	outputPath, err := filepath.Abs(args[1])
+	if err != nil {
+		return err
+	}
+
+	if inputPath == outputPath {
+		return fmt.Errorf("output archive can't be the same as the input archive")
+	}
+
+	archive, err := renpyarchivetool.Load(inputPath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := renpyarchivetool.Convert(archive, out, version, key); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
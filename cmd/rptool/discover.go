@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tw1nk/renpyarchivetool"
+)
+
+func addDiscoverFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolP("recursive", "R", false, "search folders recursively for archives, skipping the renpy and lib folders")
+	cmd.Flags().String("pattern", renpyarchivetool.DefaultArchivePattern, "glob archives in folders have to match, use ** to match across folders")
+	cmd.Flags().Bool("game-dir", false, "only search the game folder of a Ren'Py install if there is one")
+}
+
+func discoverOptions(cmd *cobra.Command) (renpyarchivetool.DiscoverOptions, error) {
+	var opts renpyarchivetool.DiscoverOptions
+	var err error
+
+	if opts.Recursive, err = cmd.Flags().GetBool("recursive"); err != nil {
+		return opts, err
+	}
+
+	if opts.Pattern, err = cmd.Flags().GetString("pattern"); err != nil {
+		return opts, err
+	}
+
+	if opts.GameDir, err = cmd.Flags().GetBool("game-dir"); err != nil {
+		return opts, err
+	}
+
+	// folders are only skipped when searching recursively
+	opts.SkipDirs = renpyarchivetool.DefaultSkipDirs
+
+	return opts, nil
+}
+
+// archiveFiles returns filename if it is a file, or the archives discovered
+// in it if it is a folder.
+func archiveFiles(cmd *cobra.Command, filename string) ([]string, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{filename}, nil
+	}
+
+	opts, err := discoverOptions(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return renpyarchivetool.DiscoverArchives(filename, opts)
+}
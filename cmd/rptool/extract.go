@@ -1,13 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strings"
 
-	"github.com/gabriel-vasile/mimetype"
-	"github.com/mattn/go-zglob"
 	"github.com/spf13/cobra"
 	"github.com/tw1nk/renpyarchivetool"
 )
@@ -16,102 +17,222 @@ var extractCmd *cobra.Command
 
 func init() {
 	extractCmd = &cobra.Command{
-		Use:   "extract",
+		Use:   "extract <archive or folder> [file names...]",
 		Short: "Extract files from Ren'Py archives",
 		RunE:  extract,
 		Args:  cobra.MinimumNArgs(1),
 	}
 
 	extractCmd.Flags().StringP("output", "o", ".", "output folder")
+	extractCmd.Flags().String("to-tar", "", "write the files to this tar file instead of a folder, - for stdout")
+	extractCmd.Flags().String("to-zip", "", "write the files to this zip file instead of a folder, - for stdout")
 	extractCmd.Flags().BoolP("use-mime-detector", "m", false, "use mime detector to give files correct extensions")
+	extractCmd.Flags().Bool("sanitize-paths", false, "extract files with names like ../x or /x inside the output folder instead of skipping them")
+	extractCmd.Flags().String("overwrite", "always", "what to do with existing files: always, never, if-different or if-newer than the archive")
+	extractCmd.Flags().String("skip-existing", "", "skip existing files with the same size or content, --skip-existing is --skip-existing=content")
+	extractCmd.Flags().Lookup("skip-existing").NoOptDefVal = "content"
+	extractCmd.Flags().IntP("jobs", "j", 1, "number of files to extract at the same time")
+	extractCmd.Flags().Bool("no-progress", false, "don't show a progress bar")
+	extractCmd.Flags().Bool("fail-fast", false, "stop at the first file that fails to extract")
+	extractCmd.Flags().BoolP("verbose", "v", false, "log every extracted file")
+	extractCmd.Flags().BoolP("quiet", "q", false, "don't log failed files and summaries")
+	addDiscoverFlags(extractCmd)
+	addFilterFlags(extractCmd)
 }
 
 func extract(cmd *cobra.Command, args []string) error {
-	filename := args[0]
+	files, err := archiveFiles(cmd, args[0])
+	if err != nil {
+		return err
+	}
 
-	info, err := os.Stat(filename)
+	outputFolder, err := cmd.Flags().GetString("output")
 	if err != nil {
 		return err
 	}
 
-	if info.IsDir() {
-		files, err := zglob.Glob(filepath.Join(filename, "*.rpa"))
-		if err != nil {
-			return err
+	outputFolder, err = filepath.Abs(outputFolder)
+	if err != nil {
+		return err
+	}
+
+	opts, err := extractOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	if opts.Filter, err = fileFilter(cmd, args[1:]); err != nil {
+		return err
+	}
+
+	toTar, err := cmd.Flags().GetString("to-tar")
+	if err != nil {
+		return err
+	}
+
+	toZip, err := cmd.Flags().GetString("to-zip")
+	if err != nil {
+		return err
+	}
+
+	if toTar != "" || toZip != "" {
+		// these only apply to files written to a folder
+		for _, flag := range []string{"output", "overwrite", "skip-existing", "jobs", "use-mime-detector"} {
+			if cmd.Flags().Changed(flag) {
+				return fmt.Errorf("--%s can't be used with --to-tar or --to-zip", flag)
+			}
 		}
+	}
+
+	switch {
+	case toTar != "" && toZip != "":
+		return fmt.Errorf("--to-tar and --to-zip can't be used together")
+	case toTar != "":
+		return extractToStream(files, toTar, false, opts)
+	case toZip != "":
+		return extractToStream(files, toZip, true, opts)
+	}
+
+	noProgress, err := cmd.Flags().GetBool("no-progress")
+	if err != nil {
+		return err
+	}
 
-		for _, filename := range files {
-			extractFile(filename)
+	// a progress bar would be garbled by logging every file
+	showProgress := !noProgress && opts.LogLevel < renpyarchivetool.LogFiles && isTerminal(os.Stderr)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	failed := 0
+	for _, filename := range files {
+		if err := extractFile(ctx, filename, outputFolder, opts, showProgress); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+
+			if !opts.ContinueOnError {
+				return err
+			}
+
+			// failed files have already been logged by ExtractAll
+			var extractErr *renpyarchivetool.ExtractError
+			if !errors.As(err, &extractErr) && opts.LogLevel >= renpyarchivetool.LogErrors {
+				log.Printf("%s: %v", filename, err)
+			}
+			failed++
 		}
+	}
 
-	} else {
-		extractFile(filename)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d archives failed to extract", failed, len(files))
 	}
 
 	return nil
 }
 
-func extractFile(filename string) error {
-	outputFolder, err := extractCmd.Flags().GetString("output")
+func extractOptions(cmd *cobra.Command) (renpyarchivetool.ExtractOptions, error) {
+	var opts renpyarchivetool.ExtractOptions
+
+	useMimeDetector, err := cmd.Flags().GetBool("use-mime-detector")
 	if err != nil {
-		return err
+		return opts, err
 	}
 
-	outputFolder, err = filepath.Abs(outputFolder)
+	sanitizePaths, err := cmd.Flags().GetBool("sanitize-paths")
 	if err != nil {
-		return err
+		return opts, err
 	}
 
-	useMimeDetector, err := extractCmd.Flags().GetBool("use-mime-detector")
+	overwrite, err := cmd.Flags().GetString("overwrite")
 	if err != nil {
-		return err
+		return opts, err
 	}
 
-	archive, err := renpyarchivetool.Load(filename)
-	if err != nil {
-		return err
+	if opts.Overwrite, err = renpyarchivetool.ParseOverwritePolicy(overwrite); err != nil {
+		return opts, err
 	}
 
-	return exctractArchive(archive, outputFolder, useMimeDetector)
-}
+	skipExisting, err := cmd.Flags().GetString("skip-existing")
+	if err != nil {
+		return opts, err
+	}
 
-func exctractArchive(
-	archive *renpyarchivetool.RenPyArchive,
-	outputFolder string,
-	useMimeDetector bool,
-) error {
-	for _, filename := range archive.FileNames() {
-		outputPath := filepath.Join(outputFolder, filename)
-		dirName := filepath.Dir(outputPath)
-		if err := os.MkdirAll(dirName, os.ModePerm); err != nil {
-			return err
+	if skipExisting != "" {
+		if cmd.Flags().Changed("overwrite") {
+			return opts, fmt.Errorf("--skip-existing can't be used with --overwrite")
 		}
 
-		fileData, err := archive.Read(filename)
-		if err != nil {
-			return err
+		opts.Overwrite = renpyarchivetool.OverwriteIfDifferent
+		switch skipExisting {
+		case "content":
+			opts.Compare = renpyarchivetool.CompareContent
+		case "size":
+			opts.Compare = renpyarchivetool.CompareSize
+		default:
+			return opts, fmt.Errorf("unknown --skip-existing mode: %s", skipExisting)
 		}
+	}
 
-		if useMimeDetector && !strings.HasSuffix(outputPath, ".rpy") {
-			fileType := mimetype.Detect(fileData)
-			if !strings.HasSuffix(outputPath, fileType.Extension()) {
-				log.Printf("Correcting file extension of: %s to: %s",
-					outputPath,
-					fileType.Extension(),
-				)
-				outputPath += fileType.Extension()
-			}
+	jobs, err := cmd.Flags().GetInt("jobs")
+	if err != nil {
+		return opts, err
+	}
 
-			log.Printf("Extracting %s", outputPath)
+	failFast, err := cmd.Flags().GetBool("fail-fast")
+	if err != nil {
+		return opts, err
+	}
 
-			err = os.WriteFile(outputPath, fileData, os.ModePerm)
-			if err != nil {
-				return err
-			}
+	verbose, err := cmd.Flags().GetBool("verbose")
+	if err != nil {
+		return opts, err
+	}
 
-		}
+	quiet, err := cmd.Flags().GetBool("quiet")
+	if err != nil {
+		return opts, err
+	}
 
+	opts.FixExtensions = useMimeDetector
+	opts.SanitizePaths = sanitizePaths
+	opts.Jobs = jobs
+	opts.ContinueOnError = !failFast
+
+	switch {
+	case quiet:
+		opts.LogLevel = renpyarchivetool.LogQuiet
+	case verbose:
+		opts.LogLevel = renpyarchivetool.LogFiles
+	default:
+		opts.LogLevel = renpyarchivetool.LogErrors
 	}
 
-	return nil
+	return opts, nil
+}
+
+func extractFile(
+	ctx context.Context,
+	filename string,
+	outputFolder string,
+	opts renpyarchivetool.ExtractOptions,
+	showProgress bool,
+) error {
+	archive, err := renpyarchivetool.Load(filename)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	if showProgress {
+		bar := newProgressBar(os.Stderr, filepath.Base(filename))
+		defer bar.Finish()
+
+		opts.OnProgress = bar.Update
+		opts.Logger = log.New(bar, log.Prefix(), log.Flags())
+	}
+
+	_, err = renpyarchivetool.ExtractAllContext(ctx, archive, outputFolder, opts)
+
+	return err
 }
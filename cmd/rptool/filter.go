@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tw1nk/renpyarchivetool"
+)
+
+func addFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceP("include", "i", nil, "only use files matching these globs, ** matches across folders")
+	cmd.Flags().StringSliceP("exclude", "e", nil, "skip files matching these globs")
+	cmd.Flags().StringSlice("regex", nil, "only use files matching these regular expressions")
+	cmd.Flags().StringSlice("exclude-regex", nil, "skip files matching these regular expressions")
+	cmd.Flags().String("min-size", "", "skip files smaller than this, like 512, 10K or 2M")
+	cmd.Flags().String("max-size", "", "skip files larger than this")
+}
+
+// fileFilter builds the filter set by the flags added by addFilterFlags.
+// names are exact file names to select, if any.
+func fileFilter(cmd *cobra.Command, names []string) (*renpyarchivetool.Filter, error) {
+	filter := &renpyarchivetool.Filter{Names: names}
+	var err error
+
+	if filter.Include, err = cmd.Flags().GetStringSlice("include"); err != nil {
+		return nil, err
+	}
+
+	if filter.Exclude, err = cmd.Flags().GetStringSlice("exclude"); err != nil {
+		return nil, err
+	}
+
+	if filter.Regexps, err = regexpsFlag(cmd, "regex"); err != nil {
+		return nil, err
+	}
+
+	if filter.ExcludeRegexps, err = regexpsFlag(cmd, "exclude-regex"); err != nil {
+		return nil, err
+	}
+
+	if filter.MinSize, err = sizeFlag(cmd, "min-size"); err != nil {
+		return nil, err
+	}
+
+	if filter.MaxSize, err = sizeFlag(cmd, "max-size"); err != nil {
+		return nil, err
+	}
+
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+func regexpsFlag(cmd *cobra.Command, name string) ([]*regexp.Regexp, error) {
+	patterns, err := cmd.Flags().GetStringSlice(name)
+	if err != nil {
+		return nil, err
+	}
+
+	regexps := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s %s. %w", name, pattern, err)
+		}
+		regexps = append(regexps, re)
+	}
+
+	return regexps, nil
+}
+
+// sizeFlag parses a size with an optional K, M or G suffix, like 10K or
+// 10KiB, zero if unset.
+func sizeFlag(cmd *cobra.Command, name string) (int64, error) {
+	value, err := cmd.Flags().GetString(name)
+	if err != nil || value == "" {
+		return 0, err
+	}
+
+	number := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(value), "B"), "I")
+	multiplier := int64(1)
+	for i, suffix := range []string{"K", "M", "G"} {
+		if strings.HasSuffix(number, suffix) {
+			number = strings.TrimSuffix(number, suffix)
+			multiplier = int64(1) << (10 * (i + 1))
+			break
+		}
+	}
+
+	size, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid --%s %s", name, value)
+	}
+
+	return size * multiplier, nil
+}
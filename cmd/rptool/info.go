@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/tw1nk/renpyarchivetool"
+)
+
+var infoCmd *cobra.Command
+
+func init() {
+	infoCmd = &cobra.Command{
+		Use:   "info",
+		Short: "Show header and index information of Ren'Py archives",
+		RunE:  info,
+		Args:  cobra.MinimumNArgs(1),
+	}
+
+	addDiscoverFlags(infoCmd)
+}
+
+func info(cmd *cobra.Command, args []string) error {
+	for _, filename := range args {
+		files, err := archiveFiles(cmd, filename)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			if err := infoFile(file); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func infoFile(filename string) error {
+	archive, err := renpyarchivetool.Load(filename)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	info := archive.Info()
+
+	fmt.Printf("File:             %s\n", filename)
+	fmt.Printf("Version:          %s\n", info.Version)
+	fmt.Printf("Scheme:           %s\n", info.Scheme)
+	fmt.Printf("Header:           %q\n", info.Header)
+	fmt.Printf("Key:              0x%x\n", info.Key)
+	fmt.Printf("Size:             %d\n", info.Size)
+	fmt.Printf("Index offset:     %d\n", info.IndexOffset)
+	fmt.Printf("Index size:       %d compressed, %d uncompressed\n", info.IndexCompressedSize, info.IndexSize)
+	fmt.Printf("Pickle protocol:  %d\n", info.PickleProtocol)
+	fmt.Printf("Entries:          %d in %d segments\n", info.Entries, info.Segments)
+	fmt.Printf("Payload size:     %d (%d in prefixes)\n", info.PayloadSize, info.PrefixSize)
+	fmt.Printf("Unreferenced:     %d\n", info.SlackSize)
+	fmt.Printf("Overlaps:         %d\n", len(info.Overlaps))
+	for _, overlap := range info.Overlaps {
+		fmt.Printf("  %s and %s share %d bytes at %d\n", overlap.Name, overlap.OtherName, overlap.Length, overlap.Offset)
+	}
+	fmt.Println()
+
+	return nil
+}
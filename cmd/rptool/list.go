@@ -1,56 +1,246 @@
 package main
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"sort"
+	"strconv"
+	"text/tabwriter"
 
-	"github.com/mattn/go-zglob"
+	"github.com/gabriel-vasile/mimetype"
 	"github.com/spf13/cobra"
 	"github.com/tw1nk/renpyarchivetool"
 )
 
-var listCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List files from Ren'Py archives",
-	RunE:  list,
-	Args:  cobra.MinimumNArgs(1),
+var listCmd *cobra.Command
+
+func init() {
+	listCmd = &cobra.Command{
+		Use:   "list <archive or folder> [file names...]",
+		Short: "List files from Ren'Py archives",
+		RunE:  list,
+		Args:  cobra.MinimumNArgs(1),
+	}
+
+	listCmd.Flags().BoolP("long", "l", false, "show offset, length, prefix length and detected mime type")
+	listCmd.Flags().BoolP("human-readable", "H", false, "show sizes in long listings as KiB, MiB, ...")
+	listCmd.Flags().String("sort", "name", "sort files by name, size or offset")
+	listCmd.Flags().StringP("format", "f", "text", "output format: text, json, csv or tsv")
+	addDiscoverFlags(listCmd)
+	addFilterFlags(listCmd)
+}
+
+type listEntry struct {
+	Archive      string `json:"archive"`
+	Name         string `json:"name"`
+	Offset       int64  `json:"offset"`
+	Length       int64  `json:"length"`
+	PrefixLength int    `json:"prefix_length"`
+	MimeType     string `json:"mime_type,omitempty"`
+}
+
+type listOptions struct {
+	filter *renpyarchivetool.Filter
+	long   bool
+	human  bool
+	sortBy string
+	format string
 }
 
 func list(cmd *cobra.Command, args []string) error {
+	var opts listOptions
+	var err error
 
-	filename := args[0]
+	if opts.long, err = cmd.Flags().GetBool("long"); err != nil {
+		return err
+	}
 
-	info, err := os.Stat(filename)
+	if opts.human, err = cmd.Flags().GetBool("human-readable"); err != nil {
+		return err
+	}
+
+	if opts.sortBy, err = cmd.Flags().GetString("sort"); err != nil {
+		return err
+	}
+
+	if opts.format, err = cmd.Flags().GetString("format"); err != nil {
+		return err
+	}
+
+	if opts.filter, err = fileFilter(cmd, args[1:]); err != nil {
+		return err
+	}
+
+	switch opts.sortBy {
+	case "name", "size", "offset":
+	default:
+		return fmt.Errorf("unknown sort order: %s", opts.sortBy)
+	}
+
+	switch opts.format {
+	case "text", "json", "csv", "tsv":
+	default:
+		return fmt.Errorf("unknown format: %s", opts.format)
+	}
+
+	files, err := archiveFiles(cmd, args[0])
 	if err != nil {
 		return err
 	}
 
-	if info.IsDir() {
-		files, err := zglob.Glob(filepath.Join(filename, "*.rpa"))
+	entries := make([]listEntry, 0)
+	for _, filename := range files {
+		archiveEntries, err := listFile(filename, opts)
 		if err != nil {
 			return err
 		}
 
-		for _, filename := range files {
-			listFile(filename)
+		if opts.format == "text" {
+			printText(os.Stdout, archiveEntries, opts)
+			continue
 		}
 
-	} else {
-		listFile(filename)
+		entries = append(entries, archiveEntries...)
+	}
+
+	switch opts.format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	case "csv":
+		return printCSV(os.Stdout, entries, ',')
+	case "tsv":
+		return printCSV(os.Stdout, entries, '\t')
 	}
 
 	return nil
 }
 
-func listFile(filename string) error {
+func listFile(filename string, opts listOptions) ([]listEntry, error) {
 	archive, err := renpyarchivetool.Load(filename)
 	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	archiveEntries := archive.Entries(renpyarchivetool.WalkOptions{Filter: opts.filter})
+
+	entries := make([]listEntry, 0, len(archiveEntries))
+	for _, archiveEntry := range archiveEntries {
+		name, index := archiveEntry.Name, archiveEntry.Index
+		entry := listEntry{
+			Archive: filename,
+			Name:    name,
+			Offset:  index.Offset,
+			Length:  index.Length,
+		}
+
+		for _, segment := range index.Segments {
+			entry.PrefixLength += len(segment.Prefix)
+		}
+
+		if opts.long {
+			file, err := archive.Open(name)
+			if err != nil {
+				return nil, err
+			}
+
+			fileType, err := mimetype.DetectReader(file)
+			if err != nil {
+				return nil, err
+			}
+			entry.MimeType = fileType.String()
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		switch opts.sortBy {
+		case "size":
+			if a.Length != b.Length {
+				return a.Length < b.Length
+			}
+		case "offset":
+			if a.Offset != b.Offset {
+				return a.Offset < b.Offset
+			}
+		}
+		return a.Name < b.Name
+	})
+
+	return entries, nil
+}
+
+func printText(w io.Writer, entries []listEntry, opts listOptions) {
+	if !opts.long {
+		for _, entry := range entries {
+			fmt.Fprintln(w, entry.Name)
+		}
+		return
+	}
+
+	var total int64
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, entry := range entries {
+		length := strconv.FormatInt(entry.Length, 10)
+		if opts.human {
+			length = humanSize(entry.Length)
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%d\t %s\t %s\n", entry.Offset, length, entry.PrefixLength, entry.MimeType, entry.Name)
+		total += entry.Length
+	}
+	tw.Flush()
+
+	if len(entries) > 0 {
+		fmt.Fprintf(w, "%s: %d files, %s\n", entries[0].Archive, len(entries), humanSize(total))
+	}
+}
+
+func printCSV(w io.Writer, entries []listEntry, separator rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = separator
+
+	if err := cw.Write([]string{"archive", "name", "offset", "length", "prefix_length", "mime_type"}); err != nil {
 		return err
 	}
 
-	for _, file := range archive.FileNames() {
-		println(file)
+	for _, entry := range entries {
+		err := cw.Write([]string{
+			entry.Archive,
+			entry.Name,
+			strconv.FormatInt(entry.Offset, 10),
+			strconv.FormatInt(entry.Length, 10),
+			strconv.Itoa(entry.PrefixLength),
+			entry.MimeType,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
 	}
 
-	return nil
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
 }
@@ -9,12 +9,18 @@ func main() {
 	rootCmd := &cobra.Command{
 		Use:   "rptool",
 		Short: "Ren'Py Archive Tool",
-		Long:  `A tool to extract and list files from Ren'Py archives.`,
+		Long:  `A tool to extract, list and pack files in Ren'Py archives.`,
 	}
 
 	rootCmd.AddCommand(extractCmd)
 	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(infoCmd)
 	rootCmd.AddCommand(mountCmd)
+	rootCmd.AddCommand(packCmd)
+	rootCmd.AddCommand(convertCmd)
+	rootCmd.AddCommand(addCmd)
+	rootCmd.AddCommand(rmCmd)
+	rootCmd.AddCommand(compactCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		panic(err)
@@ -17,6 +17,9 @@ func init() {
 		RunE:  mountFunc,
 		Args:  cobra.ExactArgs(2),
 	}
+
+	addDiscoverFlags(mountCmd)
+	addFilterFlags(mountCmd)
 }
 
 func mountFunc(cmd *cobra.Command, args []string) error {
@@ -28,15 +31,25 @@ func mountFunc(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	var opts mount.Options
+
+	if opts.Filter, err = fileFilter(cmd, nil); err != nil {
+		return err
+	}
+
 	var ctrl mount.Controller
 
 	if info.IsDir() {
-		ctrl, err = mount.Directory(mountpoint, filename)
+		if opts.Discover, err = discoverOptions(cmd); err != nil {
+			return err
+		}
+
+		ctrl, err = mount.DirectoryWithOptions(mountpoint, filename, opts)
 		if err != nil {
 			return err
 		}
 	} else {
-		ctrl, err = mount.Archive(mountpoint, filename)
+		ctrl, err = mount.ArchiveWithOptions(mountpoint, filename, opts)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tw1nk/renpyarchivetool"
+)
+
+var packCmd *cobra.Command
+
+func init() {
+	packCmd = &cobra.Command{
+		Use:   "pack <source folder> <output.rpa>",
+		Short: "Pack a folder into a Ren'Py archive",
+		RunE:  pack,
+		Args:  cobra.ExactArgs(2),
+	}
+
+	packCmd.Flags().String("rpa-version", "3.0", "archive version to create (2.0, 3.0 or 3.2)")
+	packCmd.Flags().StringP("key", "k", "random", "obfuscation key as hex, or \"random\"")
+	addFilterFlags(packCmd)
+	packCmd.Flags().IntP("prefix-length", "p", 0, "number of leading bytes of each file to store in the index")
+}
+
+func pack(cmd *cobra.Command, args []string) error {
+	sourceFolder := args[0]
+	outputPath := args[1]
+
+	versionString, err := cmd.Flags().GetString("rpa-version")
+	if err != nil {
+		return err
+	}
+
+	version, err := renpyarchivetool.ParseVersion(versionString)
+	if err != nil {
+		return err
+	}
+
+	keyString, err := cmd.Flags().GetString("key")
+	if err != nil {
+		return err
+	}
+
+	key, err := parseKey(keyString, cmd.Flags().Changed("key"), version)
+	if err != nil {
+		return err
+	}
+
+	filter, err := fileFilter(cmd, nil)
+	if err != nil {
+		return err
+	}
+
+	prefixLength, err := cmd.Flags().GetInt("prefix-length")
+	if err != nil {
+		return err
+	}
+
+	outputPath, err = filepath.Abs(outputPath)
+	if err != nil {
+		return err
+	}
+
+	files, err := packFileList(sourceFolder, outputPath, filter)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	writer, err := renpyarchivetool.NewWriter(out, version, key)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range files {
+		log.Printf("Packing %s", name)
+
+		if err := packFile(writer, filepath.Join(sourceFolder, filepath.FromSlash(name)), name, prefixLength); err != nil {
+			return err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+func packFile(writer *renpyarchivetool.Writer, path string, name string, prefixLength int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return writer.AddFilePrefixed(name, f, prefixLength)
+}
+
+// packFileList returns the archive names of all files below sourceFolder
+// selected by filter, in lexical order.
+func packFileList(sourceFolder string, outputPath string, filter *renpyarchivetool.Filter) ([]string, error) {
+	files := make([]string, 0)
+
+	err := filepath.WalkDir(sourceFolder, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		if absPath, err := filepath.Abs(path); err == nil && absPath == outputPath {
+			return nil
+		}
+
+		rel, err := filepath.Rel(sourceFolder, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if !filter.Match(name, info.Size()) {
+			return nil
+		}
+
+		files = append(files, name)
+
+		return nil
+	})
+
+	return files, err
+}
+
+// parseKey parses the --key flag, changed tells whether it was given on the
+// command line, since RPA-2.0 archives have no key to set.
+func parseKey(keyString string, changed bool, version renpyarchivetool.RPAVersion) (int64, error) {
+	if version == renpyarchivetool.RPAVersion2 {
+		if changed {
+			return 0, fmt.Errorf("%sarchives have no key, don't pass --key", version)
+		}
+		return 0, nil
+	}
+
+	if keyString == "random" {
+		var buf [4]byte
+		if _, err := rand.Read(buf[:]); err != nil {
+			return 0, err
+		}
+
+		return int64(binary.LittleEndian.Uint32(buf[:])), nil
+	}
+
+	// Ren'Py reads the key from a fixed width field of 8 hex digits
+	key, err := strconv.ParseUint(strings.TrimPrefix(keyString, "0x"), 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid key: %s. %v", keyString, err)
+	}
+
+	return int64(key), nil
+}
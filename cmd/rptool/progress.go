@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const progressBarWidth = 30
+
+// progressBar draws the progress of an extraction on a terminal. Log output
+// written to it is printed above the bar.
+type progressBar struct {
+	mu      sync.Mutex
+	w       io.Writer
+	label   string
+	line    string
+	drawn   time.Time
+	visible bool
+}
+
+func newProgressBar(w io.Writer, label string) *progressBar {
+	return &progressBar{w: w, label: label}
+}
+
+// isTerminal reports whether f is a character device like a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Update redraws the bar, at most ten times a second unless done.
+func (p *progressBar) Update(done int, total int, bytes int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if done < total && time.Since(p.drawn) < 100*time.Millisecond {
+		return
+	}
+
+	filled := progressBarWidth
+	if total > 0 {
+		filled = progressBarWidth * done / total
+	}
+
+	p.line = fmt.Sprintf("%s [%s%s] %d/%d files, %s",
+		p.label,
+		strings.Repeat("=", filled),
+		strings.Repeat(" ", progressBarWidth-filled),
+		done, total, humanSize(bytes),
+	)
+	p.draw()
+}
+
+// Finish ends the line of the bar.
+func (p *progressBar) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.visible {
+		fmt.Fprintln(p.w)
+		p.visible = false
+	}
+	p.line = ""
+}
+
+func (p *progressBar) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clear()
+	n, err := p.w.Write(b)
+	if p.line != "" {
+		p.draw()
+	}
+
+	return n, err
+}
+
+func (p *progressBar) clear() {
+	if p.visible {
+		fmt.Fprint(p.w, "\r\033[K")
+		p.visible = false
+	}
+}
+
+func (p *progressBar) draw() {
+	p.clear()
+	fmt.Fprint(p.w, p.line)
+	p.drawn = time.Now()
+	p.visible = true
+}
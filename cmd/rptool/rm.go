@@ -0,0 +1,37 @@
+package main
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/tw1nk/renpyarchivetool"
+)
+
+var rmCmd = &cobra.Command{
+	Use:   "rm <archive.rpa> <name>...",
+	Short: "Remove files from a Ren'Py archive",
+	RunE:  rm,
+	Args:  cobra.MinimumNArgs(2),
+}
+
+func rm(cmd *cobra.Command, args []string) error {
+	editor, err := renpyarchivetool.OpenEditor(args[0])
+	if err != nil {
+		return err
+	}
+	defer editor.Close()
+
+	for _, name := range args[1:] {
+		log.Printf("Removing %s", name)
+
+		if err := editor.Delete(name); err != nil {
+			return err
+		}
+	}
+
+	if err := editor.Commit(); err != nil {
+		return err
+	}
+
+	return editor.Close()
+}
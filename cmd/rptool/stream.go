@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/tw1nk/renpyarchivetool"
+)
+
+type archiveStream interface {
+	AddArchive(rp *renpyarchivetool.RenPyArchive, opts renpyarchivetool.StreamOptions) (*renpyarchivetool.ExtractSummary, error)
+	Close() error
+}
+
+// extractToStream writes the files of all archives to a single tar or zip
+// stream at target, which is stdout if target is "-".
+func extractToStream(files []string, target string, toZip bool, opts renpyarchivetool.ExtractOptions) error {
+	if target == "-" {
+		return writeStream(os.Stdout, files, toZip, opts)
+	}
+
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+
+	streamErr := writeStream(f, files, toZip, opts)
+
+	var extractErr *renpyarchivetool.ExtractError
+	if err := f.Close(); err != nil && (streamErr == nil || errors.As(streamErr, &extractErr)) {
+		streamErr = err
+	}
+
+	// don't leave a broken stream behind, files left out don't break it
+	if streamErr != nil && !errors.As(streamErr, &extractErr) {
+		os.Remove(target)
+	}
+
+	return streamErr
+}
+
+func writeStream(out io.Writer, files []string, toZip bool, opts renpyarchivetool.ExtractOptions) error {
+	var stream archiveStream
+	if toZip {
+		stream = renpyarchivetool.NewZipWriter(out)
+	} else {
+		stream = renpyarchivetool.NewTarWriter(out)
+	}
+
+	streamOpts := renpyarchivetool.StreamOptions{
+		SanitizePaths: opts.SanitizePaths,
+		Filter:        opts.Filter,
+	}
+
+	leftOut := make([]error, 0)
+	for _, filename := range files {
+		err := streamFile(stream, filename, streamOpts, opts)
+		if err == nil {
+			continue
+		}
+
+		// files with unsafe names are left out, everything else breaks the
+		// stream
+		var extractErr *renpyarchivetool.ExtractError
+		if !errors.As(err, &extractErr) {
+			return err
+		}
+
+		if !opts.ContinueOnError {
+			// the stream is left unfinished, so this must not be an
+			// *ExtractError
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+
+		leftOut = append(leftOut, extractErr.Errors...)
+	}
+
+	if err := stream.Close(); err != nil {
+		return err
+	}
+
+	if len(leftOut) > 0 {
+		return &renpyarchivetool.ExtractError{Errors: leftOut}
+	}
+
+	return nil
+}
+
+func streamFile(stream archiveStream, filename string, streamOpts renpyarchivetool.StreamOptions, opts renpyarchivetool.ExtractOptions) error {
+	archive, err := renpyarchivetool.Load(filename)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	summary, err := stream.AddArchive(archive, streamOpts)
+
+	var extractErr *renpyarchivetool.ExtractError
+	if errors.As(err, &extractErr) && opts.LogLevel >= renpyarchivetool.LogErrors {
+		for _, fileErr := range extractErr.Errors {
+			log.Println(fileErr)
+		}
+	}
+
+	if summary != nil && opts.LogLevel >= renpyarchivetool.LogErrors {
+		log.Printf("%s: %s", filename, summary)
+	}
+
+	return err
+}
@@ -0,0 +1,29 @@
+package renpyarchivetool
+
+import (
+	"fmt"
+	"io"
+)
+
+// Convert writes all files of src to a new archive of the given version and
+// key. Files are streamed from src, nothing is buffered in memory or on disk.
+func Convert(src *RenPyArchive, dst io.WriteSeeker, version RPAVersion, key int64) error {
+	writer, err := NewWriter(dst, version, key)
+	if err != nil {
+		return err
+	}
+
+	// copy files in the order they are stored to keep reads sequential
+	for _, entry := range src.Entries(WalkOptions{ByOffset: true}) {
+		f, err := src.Open(entry.Name)
+		if err != nil {
+			return err
+		}
+
+		if err := writer.AddFile(entry.Name, f); err != nil {
+			return fmt.Errorf("failed to convert file %s. %w", entry.Name, err)
+		}
+	}
+
+	return writer.Close()
+}
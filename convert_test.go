@@ -0,0 +1,43 @@
+package renpyarchivetool
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	src := openArchive(t, RPAVersion32, 0xdeadbeef, testFiles, 4)
+
+	tests := []struct {
+		version RPAVersion
+		key     int64
+	}{
+		{RPAVersion2, 0},
+		{RPAVersion3, 0x1234abcd},
+		{RPAVersion32, 0xdeadbeef},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.TrimSpace(tt.version.String()), func(t *testing.T) {
+			out := &memFile{}
+			if err := Convert(src, out, tt.version, tt.key); err != nil {
+				t.Fatal(err)
+			}
+
+			archive, err := NewReader(out, int64(len(out.data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if archive.version != tt.version || archive.key != tt.key {
+				t.Errorf("converted to %s with key %x, want %s with key %x", archive.version, archive.key, tt.version, tt.key)
+			}
+
+			checkContents(t, archive, testFiles)
+		})
+	}
+
+	if err := Convert(src, &memFile{}, RPAVersion2, 0x42); err == nil {
+		t.Error("converting to RPA-2.0 with a key succeeded")
+	}
+}
@@ -0,0 +1,221 @@
+package renpyarchivetool
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Editor modifies an archive in place. New and replaced files are appended
+// to the end of the archive and Commit writes a new index after them and
+// updates the header, so the data of unchanged files is never copied. The
+// space used by replaced or deleted files and by old indexes is only
+// reclaimed by Compact.
+type Editor struct {
+	file    *os.File
+	version RPAVersion
+	key     int64
+
+	headerSize int64
+	offset     int64
+	// committed is the size of the archive after the last commit
+	committed int64
+	indexes   map[string]*Index
+	changed   bool
+}
+
+// OpenEditor opens an RPA-2.0, RPA-3.0 or RPA-3.2 archive for editing.
+func OpenEditor(fileName string) (*Editor, error) {
+	file, err := os.OpenFile(fileName, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %s. %w", fileName, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat file: %s. %w", fileName, err)
+	}
+
+	archive, err := NewReader(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	switch archive.version {
+	case RPAVersion2, RPAVersion3, RPAVersion32:
+	default:
+		file.Close()
+		return nil, fmt.Errorf("%w: editing %s archives", ErrUnsupportedVersion, strings.TrimSpace(archive.version.String()))
+	}
+
+	indexes := make(map[string]*Index, len(archive.indexes))
+	for name, index := range archive.indexes {
+		indexes[name] = index
+	}
+
+	return &Editor{
+		file:       file,
+		version:    archive.version,
+		key:        archive.key,
+		headerSize: archive.headerSize,
+		offset:     info.Size(),
+		committed:  info.Size(),
+		indexes:    indexes,
+	}, nil
+}
+
+// Add stores the contents of r under name, replacing any existing file.
+// Names that extraction would reject, like "../x" or "/x", or that aren't
+// clean slash separated paths fail with ErrUnsafePath.
+func (e *Editor) Add(name string, r io.Reader) error {
+	if rel, err := safePath(name, false); err != nil || filepath.ToSlash(rel) != name {
+		return &fs.PathError{Op: "add", Path: name, Err: ErrUnsafePath}
+	}
+
+	if _, err := e.file.Seek(e.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	// even a failed copy may have written data that Close has to remove
+	e.changed = true
+
+	n, err := io.Copy(e.file, r)
+	if err != nil {
+		return fmt.Errorf("failed to write file %s. %w", name, err)
+	}
+
+	e.indexes[name] = NewIndex([]Segment{{
+		Offset: e.offset,
+		Length: n,
+	}})
+	e.offset += n
+
+	return nil
+}
+
+// Delete removes name from the archive.
+func (e *Editor) Delete(name string) error {
+	if _, ok := e.indexes[name]; !ok {
+		return &fs.PathError{Op: "delete", Path: name, Err: ErrNotExist}
+	}
+
+	delete(e.indexes, name)
+	e.changed = true
+
+	return nil
+}
+
+// Has reports whether name is in the archive.
+func (e *Editor) Has(name string) bool {
+	_, ok := e.indexes[name]
+	return ok
+}
+
+// Commit writes the index and updates the header to point to it.
+func (e *Editor) Commit() error {
+	if !e.changed {
+		return nil
+	}
+
+	names := make([]string, 0, len(e.indexes))
+	for name := range e.indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	index, err := compressIndex(names, e.indexes, e.key)
+	if err != nil {
+		return err
+	}
+
+	indexOffset := e.offset
+	header := formatHeader(e.version, indexOffset, e.key)
+	if int64(len(header)) > e.headerSize {
+		return fmt.Errorf("%w: new header needs %d bytes, only %d are available. compact the archive instead", ErrInvalidHeader, len(header), e.headerSize)
+	}
+	// pad the header to keep the data after it in place
+	header = header[:len(header)-1] + strings.Repeat(" ", int(e.headerSize)-len(header)) + "\n"
+
+	if _, err := e.file.WriteAt(index, indexOffset); err != nil {
+		return fmt.Errorf("failed to write indexes. %w", err)
+	}
+
+	if err := e.file.Truncate(indexOffset + int64(len(index))); err != nil {
+		return err
+	}
+
+	// the data has to be on disk before the header points to the new index
+	if err := e.file.Sync(); err != nil {
+		return err
+	}
+
+	if _, err := e.file.WriteAt([]byte(header), 0); err != nil {
+		return fmt.Errorf("failed to write header. %w", err)
+	}
+
+	e.offset = indexOffset + int64(len(index))
+	e.committed = e.offset
+	e.changed = false
+
+	return e.file.Sync()
+}
+
+// Close closes the archive without committing pending changes. Data added
+// since the last commit is removed again.
+func (e *Editor) Close() error {
+	if e.changed {
+		if err := e.file.Truncate(e.committed); err != nil {
+			e.file.Close()
+			return err
+		}
+		e.changed = false
+	}
+
+	return e.file.Close()
+}
+
+// Compact rewrites the archive without the space left behind by replaced or
+// deleted files and old indexes. The archive keeps its version and key.
+func Compact(fileName string) error {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return err
+	}
+
+	archive, err := Load(fileName)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if err := Convert(archive, tmp, archive.version, archive.key); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), fileName)
+}
@@ -0,0 +1,214 @@
+package renpyarchivetool
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestEditorAddUnsafeNames(t *testing.T) {
+	fileName := writeArchive(t, buildArchive(t, RPAVersion3, 0x42, map[string]string{"a.txt": "a"}, 0))
+
+	editor, err := OpenEditor(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer editor.Close()
+
+	for _, name := range []string{"../out/a.txt", "/abs", "C:/x", `dir\x`, "a/../../b", "./a", "a//b", ""} {
+		if err := editor.Add(name, strings.NewReader("x")); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("Add(%q) = %v, want ErrUnsafePath", name, err)
+		}
+	}
+
+	if err := editor.Add("dir/b.txt", strings.NewReader("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkContents(t, loadArchive(t, fileName), map[string]string{"a.txt": "a", "dir/b.txt": "b"})
+}
+
+// writeArchive writes out to a file in a temporary folder.
+func writeArchive(t *testing.T, out *memFile) string {
+	t.Helper()
+
+	fileName := filepath.Join(t.TempDir(), "archive.rpa")
+	if err := os.WriteFile(fileName, out.data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return fileName
+}
+
+func loadArchive(t *testing.T, fileName string) *RenPyArchive {
+	t.Helper()
+
+	archive, err := Load(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { archive.Close() })
+
+	return archive
+}
+
+func TestEditorRoundTrip(t *testing.T) {
+	fileName := writeArchive(t, buildArchive(t, RPAVersion32, 0xdeadbeef, testFiles, 4))
+
+	editor, err := OpenEditor(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer editor.Close()
+
+	want := make(map[string]string)
+	for name, data := range testFiles {
+		want[name] = data
+	}
+
+	if err := editor.Add("script.rpy", strings.NewReader("label start:\n    return\n")); err != nil {
+		t.Fatal(err)
+	}
+	want["script.rpy"] = "label start:\n    return\n"
+
+	if err := editor.Delete("a.rpy"); err != nil {
+		t.Fatal(err)
+	}
+	delete(want, "a.rpy")
+
+	if err := editor.Delete("a.rpy"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("deleting a deleted file = %v, want ErrNotExist", err)
+	}
+
+	if err := editor.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a second commit on top of the first one
+	if err := editor.Add("new/file.txt", strings.NewReader("new")); err != nil {
+		t.Fatal(err)
+	}
+	want["new/file.txt"] = "new"
+
+	if err := editor.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := loadArchive(t, fileName)
+	if archive.version != RPAVersion32 || archive.key != 0xdeadbeef {
+		t.Errorf("read %s with key %x, want RPA-3.2 with key deadbeef", archive.version, archive.key)
+	}
+	checkContents(t, archive, want)
+
+	if archive.Info().SlackSize == 0 {
+		t.Error("replaced and deleted files left no slack")
+	}
+	archive.Close()
+
+	if err := Compact(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	archive = loadArchive(t, fileName)
+	if archive.version != RPAVersion32 || archive.key != 0xdeadbeef {
+		t.Errorf("compacted to %s with key %x, want RPA-3.2 with key deadbeef", archive.version, archive.key)
+	}
+	if slack := archive.Info().SlackSize; slack != 0 {
+		t.Errorf("SlackSize = %d after compacting, want 0", slack)
+	}
+	checkContents(t, archive, want)
+}
+
+func TestEditorHeaderPadding(t *testing.T) {
+	// a header with more room than the editor needs keeps its size
+	header := "RPA-3.0 %016x 00000042     \n"
+	headerSize := int64(len(fmt.Sprintf(header, 0)))
+	fileName := writeArchive(t, craftArchive(t, header, "data", map[string]*Index{
+		"data.txt": NewIndex([]Segment{{Offset: headerSize, Length: 4}}),
+	}, 0x42))
+
+	editor, err := OpenEditor(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer editor.Close()
+
+	if err := editor.Add("new.txt", strings.NewReader("new")); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	editor.Close()
+
+	archive := loadArchive(t, fileName)
+	if archive.headerSize != headerSize {
+		t.Errorf("header is %d bytes, want %d", archive.headerSize, headerSize)
+	}
+	checkContents(t, archive, map[string]string{"data.txt": "data", "new.txt": "new"})
+
+	// a header without room for the new one can't be updated in place
+	header = "RPA-2.0 %08x\n"
+	headerSize = int64(len(fmt.Sprintf(header, 0)))
+	fileName = writeArchive(t, craftArchive(t, header, "data", map[string]*Index{
+		"data.txt": NewIndex([]Segment{{Offset: headerSize, Length: 4}}),
+	}, 0))
+
+	editor, err = OpenEditor(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer editor.Close()
+
+	if err := editor.Delete("data.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.Commit(); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("Commit() = %v, want ErrInvalidHeader", err)
+	}
+}
+
+func TestEditorCloseDiscardsChanges(t *testing.T) {
+	fileName := writeArchive(t, buildArchive(t, RPAVersion3, 0x42, testFiles, 0))
+	before, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	editor, err := OpenEditor(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := editor.Add("new.txt", strings.NewReader("new")); err != nil {
+		t.Fatal(err)
+	}
+	// a copy failing halfway has written data too
+	failing := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("broken")))
+	if err := editor.Add("broken.txt", failing); err == nil {
+		t.Fatal("Add with a failing reader succeeded")
+	}
+	if err := editor.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("archive changed from %d to %d bytes without a commit", len(before), len(after))
+	}
+}
@@ -0,0 +1,57 @@
+package renpyarchivetool
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+var (
+	// ErrNotArchive is returned when the data doesn't start with a known
+	// archive header.
+	ErrNotArchive = errors.New("not a Ren'Py archive")
+
+	// ErrUnsupportedVersion is returned for archives that look like Ren'Py
+	// archives but use a version this package can't handle.
+	ErrUnsupportedVersion = errors.New("unsupported archive version")
+
+	// ErrInvalidHeader is returned when the header line of an archive is
+	// malformed.
+	ErrInvalidHeader = errors.New("invalid archive header")
+
+	// ErrCorruptIndex is returned when the index can't be decoded. Errors
+	// about a single index entry are reported as *IndexError, which also
+	// matches ErrCorruptIndex.
+	ErrCorruptIndex = errors.New("corrupt archive index")
+
+	// ErrNotExist is returned when a file isn't in the archive. It matches
+	// fs.ErrNotExist.
+	ErrNotExist = fmt.Errorf("%w in archive", fs.ErrNotExist)
+
+	// ErrUnsafePath is returned when extracting a file whose name would be
+	// written outside the destination folder or through a symlink.
+	ErrUnsafePath = errors.New("unsafe path")
+
+	// ErrTruncated is returned when the archive ends before the data of a
+	// file does.
+	ErrTruncated = errors.New("archive is truncated")
+)
+
+// IndexError describes a malformed entry in the archive index.
+type IndexError struct {
+	Key string
+	Err error
+}
+
+func (e *IndexError) Error() string {
+	return fmt.Sprintf("%v: key %s: %v", ErrCorruptIndex, e.Key, e.Err)
+}
+
+func (e *IndexError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes every IndexError match ErrCorruptIndex.
+func (e *IndexError) Is(target error) bool {
+	return target == ErrCorruptIndex
+}
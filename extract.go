@@ -0,0 +1,429 @@
+package renpyarchivetool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// LogLevel controls what ExtractAll logs.
+type LogLevel int
+
+const (
+	// LogQuiet logs nothing.
+	LogQuiet LogLevel = iota
+	// LogErrors logs files that failed to extract and the summary.
+	LogErrors
+	// LogFiles also logs every extracted file and corrected extension.
+	LogFiles
+)
+
+// ExtractOptions controls how ExtractAll extracts an archive.
+type ExtractOptions struct {
+	// ContinueOnError extracts the remaining files after a file failed
+	// instead of stopping at the first error.
+	ContinueOnError bool
+	// SanitizePaths rewrites names that would be written outside the
+	// destination, like "../x" or "/x", to stay inside it instead of
+	// rejecting them.
+	SanitizePaths bool
+	// FixExtensions appends the extension of the detected mime type to file
+	// names that don't end with it. .rpy files are left as they are.
+	FixExtensions bool
+	// Overwrite decides what happens to files that already exist in the
+	// destination.
+	Overwrite OverwritePolicy
+	// Compare is how OverwriteIfDifferent compares files.
+	Compare CompareMode
+	// Filter selects the files extracted, all files if nil.
+	Filter *Filter
+	// Jobs is the number of files extracted at the same time, at least 1.
+	Jobs int
+	// OnProgress is called after each file with the number of files handled
+	// so far, the total number of files and the bytes written so far. It is
+	// called from the extracting goroutines, but never concurrently.
+	OnProgress func(done int, total int, bytes int64)
+	// LogLevel sets what is logged to Logger.
+	LogLevel LogLevel
+	// Logger receives the log messages, log.Default() if nil.
+	Logger *log.Logger
+}
+
+// ExtractSummary counts the files handled by ExtractAll.
+type ExtractSummary struct {
+	// Files counts the files written, either Created or Updated.
+	Files   int
+	Created int
+	Updated int
+	// Skipped counts the existing files kept by the OverwritePolicy.
+	Skipped int
+	Bytes   int64
+	Failed  int
+	// Rejected counts the files not extracted because of unsafe names, they
+	// aren't included in Failed.
+	Rejected int
+}
+
+func (s *ExtractSummary) String() string {
+	return fmt.Sprintf("extracted %d files (%d created, %d updated), %d skipped, %d bytes, %d failed, %d rejected",
+		s.Files, s.Created, s.Updated, s.Skipped, s.Bytes, s.Failed, s.Rejected)
+}
+
+// ExtractError is returned by ExtractAll when files failed to extract with
+// ContinueOnError set. Each error is an *fs.PathError naming the file, in
+// the order the files are stored.
+type ExtractError struct {
+	Errors []error
+}
+
+func (e *ExtractError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	return fmt.Sprintf("%d files failed to extract, first: %v", len(e.Errors), e.Errors[0])
+}
+
+// Unwrap returns the first error.
+func (e *ExtractError) Unwrap() error {
+	return e.Errors[0]
+}
+
+// ExtractAll writes the files of the archive selected by opts to dest,
+// creating folders as needed. Files whose name would end up outside dest or
+// that would be written through a symlink fail with ErrUnsafePath. Files
+// whose name ends up at the path of an earlier file fail with fs.ErrExist.
+// The summary is returned even if files failed to extract.
+func ExtractAll(rp *RenPyArchive, dest string, opts ExtractOptions) (*ExtractSummary, error) {
+	return ExtractAllContext(context.Background(), rp, dest, opts)
+}
+
+// ExtractAllContext is ExtractAll with a context. When ctx is canceled no
+// more files are started, files being written are removed and ctx.Err() is
+// returned.
+func ExtractAllContext(ctx context.Context, rp *RenPyArchive, dest string, opts ExtractOptions) (*ExtractSummary, error) {
+	if err := opts.Filter.Validate(); err != nil {
+		return &ExtractSummary{}, err
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	jobs := opts.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	// files are handed out in the order they are stored, so even with
+	// several jobs the archive is read mostly sequentially
+	entries := rp.Entries(WalkOptions{ByOffset: true, Filter: opts.Filter})
+
+	workCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	work := make(chan int)
+	go func() {
+		defer close(work)
+		for i := range entries {
+			select {
+			case work <- i:
+			case <-workCtx.Done():
+				return
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	var firstErr error
+	summary := &ExtractSummary{}
+	errs := make([]error, len(entries))
+	done := 0
+
+	// names like "a/b" and `a\b` end up at the same path, only the first one
+	// claiming it is written, so jobs never write the same file at once
+	claimed := make(map[string]bool)
+	claim := func(outputPath string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if claimed[outputPath] {
+			return false
+		}
+		claimed[outputPath] = true
+
+		return true
+	}
+
+	var wg sync.WaitGroup
+	for j := 0; j < jobs; j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range work {
+				name := entries[i].Name
+				n, result, err := extractFile(workCtx, rp, name, dest, claim, opts, logger)
+
+				// files interrupted by a cancellation aren't failures
+				if err != nil && workCtx.Err() != nil && errors.Is(err, workCtx.Err()) {
+					continue
+				}
+
+				mu.Lock()
+				if err != nil {
+					err = &fs.PathError{Op: "extract", Path: name, Err: err}
+					if errors.Is(err, ErrUnsafePath) {
+						summary.Rejected++
+					} else {
+						summary.Failed++
+					}
+					errs[i] = err
+
+					if opts.LogLevel >= LogErrors {
+						logger.Println(err)
+					}
+
+					if !opts.ContinueOnError && firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+				} else {
+					switch result {
+					case extractCreated:
+						summary.Created++
+						summary.Files++
+					case extractUpdated:
+						summary.Updated++
+						summary.Files++
+					case extractSkipped:
+						summary.Skipped++
+					}
+					summary.Bytes += n
+				}
+
+				done++
+				if opts.OnProgress != nil {
+					opts.OnProgress(done, len(entries), summary.Bytes)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return summary, firstErr
+	}
+
+	if err := ctx.Err(); err != nil {
+		return summary, err
+	}
+
+	if opts.LogLevel >= LogErrors {
+		if rp.file != "" {
+			logger.Printf("%s: %s", rp.file, summary)
+		} else {
+			logger.Print(summary)
+		}
+	}
+
+	failed := make([]error, 0)
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return summary, &ExtractError{Errors: failed}
+	}
+
+	return summary, nil
+}
+
+func extractFile(ctx context.Context, rp *RenPyArchive, name string, dest string, claim func(outputPath string) bool, opts ExtractOptions, logger *log.Logger) (int64, extractResult, error) {
+	rel, err := safePath(name, opts.SanitizePaths)
+	if err != nil {
+		return 0, extractCreated, err
+	}
+
+	if err := checkSymlinks(dest, rel); err != nil {
+		return 0, extractCreated, err
+	}
+
+	outputPath := filepath.Join(dest, rel)
+	if err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm); err != nil {
+		return 0, extractCreated, err
+	}
+
+	file, err := rp.Open(name)
+	if err != nil {
+		return 0, extractCreated, err
+	}
+
+	if opts.FixExtensions && !strings.HasSuffix(outputPath, ".rpy") {
+		fileType, err := mimetype.DetectReader(file)
+		if err != nil {
+			return 0, extractCreated, err
+		}
+
+		if !strings.HasSuffix(outputPath, fileType.Extension()) {
+			if opts.LogLevel >= LogFiles {
+				logger.Printf("Correcting file extension of: %s to: %s", outputPath, fileType.Extension())
+			}
+			outputPath += fileType.Extension()
+
+			if err := checkSymlinks(dest, rel+fileType.Extension()); err != nil {
+				return 0, extractCreated, err
+			}
+		}
+
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return 0, extractCreated, err
+		}
+	}
+
+	if !claim(outputPath) {
+		return 0, extractCreated, fmt.Errorf("%w: %s is written by another file", fs.ErrExist, outputPath)
+	}
+
+	result, err := checkExisting(outputPath, file, rp.modTime, opts)
+	if err != nil {
+		return 0, result, err
+	}
+
+	if result == extractSkipped {
+		if opts.LogLevel >= LogFiles {
+			logger.Printf("Skipping %s", outputPath)
+		}
+		return 0, result, nil
+	}
+
+	// comparing the content has read the file
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, result, err
+	}
+
+	if opts.LogLevel >= LogFiles {
+		logger.Printf("Extracting %s", outputPath)
+	}
+
+	n, err := writeFile(outputPath, &contextReader{ctx: ctx, r: file})
+
+	return n, result, err
+}
+
+// contextReader stops reading once its context is canceled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return cr.r.Read(p)
+}
+
+// writeFile copies r to outputPath, removing the file again if the copy
+// fails so no truncated files are left behind.
+func writeFile(outputPath string, r io.Reader) (int64, error) {
+	out, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(out, r)
+	if err == nil {
+		err = out.Close()
+	} else {
+		out.Close()
+	}
+
+	if err != nil {
+		os.Remove(outputPath)
+		return n, err
+	}
+
+	return n, nil
+}
+
+// safePath turns a name from the archive into a relative path of the local
+// file system. Backslashes are treated as separators, as Windows would.
+// Absolute names, names with a drive letter and names leaving the
+// destination with ".." fail with ErrUnsafePath, unless sanitize is set, in
+// which case they are rewritten to stay inside the destination.
+func safePath(name string, sanitize bool) (string, error) {
+	unsafe := false
+
+	clean := strings.ReplaceAll(name, "\\", "/")
+	if len(clean) >= 2 && clean[1] == ':' && isLetter(clean[0]) {
+		clean = clean[2:]
+		unsafe = true
+	}
+
+	if strings.HasPrefix(clean, "/") {
+		unsafe = true
+	}
+
+	parts := make([]string, 0)
+	for _, part := range strings.Split(clean, "/") {
+		switch part {
+		case "", ".":
+		case "..":
+			if len(parts) == 0 {
+				unsafe = true
+			} else {
+				parts = parts[:len(parts)-1]
+			}
+		default:
+			parts = append(parts, part)
+		}
+	}
+
+	if strings.ContainsRune(clean, 0) || len(parts) == 0 || (unsafe && !sanitize) {
+		return "", fmt.Errorf("%w: %q", ErrUnsafePath, name)
+	}
+
+	return filepath.Join(parts...), nil
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// checkSymlinks fails with ErrUnsafePath if any existing part of rel below
+// dest is a symlink, so writing to it can't end up elsewhere.
+func checkSymlinks(dest string, rel string) error {
+	current := dest
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+
+		info, err := os.Lstat(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%w: %s is a symlink", ErrUnsafePath, current)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,470 @@
+package renpyarchivetool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// checkExtracted compares the files below dest with files.
+func checkExtracted(t *testing.T, dest string, files map[string]string) {
+	t.Helper()
+
+	found := 0
+	err := filepath.WalkDir(dest, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dest, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		want, ok := files[name]
+		if !ok {
+			t.Errorf("unexpected file %s", name)
+			return nil
+		}
+		found++
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found != len(files) {
+		t.Errorf("extracted %d files, want %d", found, len(files))
+	}
+}
+
+func TestExtractAll(t *testing.T) {
+	for _, jobs := range []int{1, 4} {
+		archive := openArchive(t, RPAVersion3, 0xbeef, testFiles, 4)
+		dest := t.TempDir()
+
+		summary, err := ExtractAll(archive, dest, ExtractOptions{Jobs: jobs})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if summary.Files != len(testFiles) || summary.Created != len(testFiles) || summary.Failed != 0 {
+			t.Errorf("jobs %d: summary %s", jobs, summary)
+		}
+
+		checkExtracted(t, dest, testFiles)
+	}
+}
+
+func TestExtractAllErrors(t *testing.T) {
+	files := map[string]string{
+		"a.txt":         "a",
+		"blocked/b.txt": "b",
+		"c.txt":         "c",
+		"d/../../e.txt": "e",
+		"f.txt":         "f",
+	}
+
+	// setup makes blocked/b.txt fail, as blocked is a file
+	setup := func(t *testing.T) string {
+		dest := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dest, "blocked"), []byte("blocker"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		return dest
+	}
+
+	t.Run("continue on error", func(t *testing.T) {
+		archive := openArchive(t, RPAVersion3, 0xbeef, files, 0)
+		dest := setup(t)
+
+		summary, err := ExtractAll(archive, dest, ExtractOptions{ContinueOnError: true})
+
+		var extractErr *ExtractError
+		if !errors.As(err, &extractErr) || len(extractErr.Errors) != 2 {
+			t.Fatalf("got error %v, want an *ExtractError with 2 errors", err)
+		}
+
+		// errors are in the order the files are stored
+		var pathErr *fs.PathError
+		if !errors.As(extractErr.Errors[0], &pathErr) || pathErr.Path != "blocked/b.txt" {
+			t.Errorf("first error is %v, want one for blocked/b.txt", extractErr.Errors[0])
+		}
+		if !errors.Is(extractErr.Errors[1], ErrUnsafePath) {
+			t.Errorf("second error is %v, want ErrUnsafePath", extractErr.Errors[1])
+		}
+
+		if summary.Files != 3 || summary.Failed != 1 || summary.Rejected != 1 {
+			t.Errorf("summary %s", summary)
+		}
+
+		checkExtracted(t, dest, map[string]string{
+			"a.txt":   "a",
+			"blocked": "blocker",
+			"c.txt":   "c",
+			"f.txt":   "f",
+		})
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		archive := openArchive(t, RPAVersion3, 0xbeef, files, 0)
+		dest := setup(t)
+
+		summary, err := ExtractAll(archive, dest, ExtractOptions{Jobs: 1})
+
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) || pathErr.Path != "blocked/b.txt" {
+			t.Fatalf("got error %v, want one for blocked/b.txt", err)
+		}
+
+		if summary.Files != 1 || summary.Failed != 1 {
+			t.Errorf("summary %s", summary)
+		}
+
+		checkExtracted(t, dest, map[string]string{
+			"a.txt":   "a",
+			"blocked": "blocker",
+		})
+	})
+}
+
+func TestSafePath(t *testing.T) {
+	tests := []struct {
+		name      string
+		sanitized string // "" if rejected even when sanitizing
+		safe      bool
+	}{
+		{"a/b.txt", "a/b.txt", true},
+		{"a/./b/../c.txt", "a/c.txt", true},
+		{`a\b.txt`, "a/b.txt", true},
+		{"../../evil", "evil", false},
+		{"/abs", "abs", false},
+		{`C:\win\x`, "win/x", false},
+		{"c:x", "x", false},
+		{`a\..\..\b`, "b", false},
+		{"a/../../b", "b", false},
+		{"..", "", false},
+		{"/", "", false},
+		{"", "", false},
+		{"a/\x00b", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rel, err := safePath(tt.name, false)
+			if tt.safe {
+				if err != nil || filepath.ToSlash(rel) != tt.sanitized {
+					t.Errorf("safePath(%q, false) = %q, %v, want %q", tt.name, rel, err, tt.sanitized)
+				}
+			} else if !errors.Is(err, ErrUnsafePath) {
+				t.Errorf("safePath(%q, false) = %q, %v, want ErrUnsafePath", tt.name, rel, err)
+			}
+
+			rel, err = safePath(tt.name, true)
+			if tt.sanitized == "" {
+				if !errors.Is(err, ErrUnsafePath) {
+					t.Errorf("safePath(%q, true) = %q, %v, want ErrUnsafePath", tt.name, rel, err)
+				}
+			} else if err != nil || filepath.ToSlash(rel) != tt.sanitized {
+				t.Errorf("safePath(%q, true) = %q, %v, want %q", tt.name, rel, err, tt.sanitized)
+			}
+		})
+	}
+}
+
+func TestExtractUnsafeNames(t *testing.T) {
+	tests := []struct {
+		name      string
+		sanitized string
+	}{
+		{"../../evil", "evil"},
+		{"/abs", "abs"},
+		{`C:\win\x`, "win/x"},
+		{`a\..\..\b`, "b"},
+		{"link/x", ""},
+		{"dir/../link/y", ""},
+	}
+
+	for _, tt := range tests {
+		for _, sanitize := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/sanitize=%t", tt.name, sanitize), func(t *testing.T) {
+				archive := openArchive(t, RPAVersion3, 0xbeef, map[string]string{tt.name: "data"}, 0)
+
+				root := t.TempDir()
+				dest := filepath.Join(root, "dest")
+				outside := filepath.Join(root, "outside")
+				for _, dir := range []string{dest, outside} {
+					if err := os.Mkdir(dir, 0755); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := os.Symlink(outside, filepath.Join(dest, "link")); err != nil {
+					t.Skip("symlinks not supported:", err)
+				}
+
+				summary, err := ExtractAll(archive, dest, ExtractOptions{SanitizePaths: sanitize, ContinueOnError: true})
+
+				want := map[string]string{}
+				wantRoot := map[string]string{}
+				if sanitize && tt.sanitized != "" {
+					if err != nil {
+						t.Fatal(err)
+					}
+					want[tt.sanitized] = "data"
+					wantRoot["dest/"+tt.sanitized] = "data"
+				} else {
+					if !errors.Is(err, ErrUnsafePath) || summary.Rejected != 1 {
+						t.Fatalf("got error %v with summary %s, want ErrUnsafePath", err, summary)
+					}
+				}
+
+				checkExtracted(t, dest, want)
+				// nothing is written next to dest or through the symlink
+				checkExtracted(t, root, wantRoot)
+			})
+		}
+	}
+}
+
+func TestExtractDuplicatePaths(t *testing.T) {
+	files := map[string]string{
+		"a/b":   strings.Repeat("slash ", 100000),
+		`a\b`:   strings.Repeat("backslash ", 100000),
+		"../x":  "dot dot",
+		"x":     "x",
+		"other": "other",
+	}
+
+	for _, jobs := range []int{1, 4} {
+		archive := openArchive(t, RPAVersion3, 0xbeef, files, 0)
+		dest := t.TempDir()
+
+		summary, err := ExtractAll(archive, dest, ExtractOptions{Jobs: jobs, SanitizePaths: true, ContinueOnError: true})
+
+		var extractErr *ExtractError
+		if !errors.As(err, &extractErr) || len(extractErr.Errors) != 2 {
+			t.Fatalf("jobs %d: got error %v, want an *ExtractError with 2 errors", jobs, err)
+		}
+		for _, err := range extractErr.Errors {
+			if !errors.Is(err, fs.ErrExist) {
+				t.Errorf("jobs %d: got error %v, want fs.ErrExist", jobs, err)
+			}
+		}
+		if summary.Files != 3 || summary.Failed != 2 {
+			t.Errorf("jobs %d: summary %s", jobs, summary)
+		}
+
+		// whichever file won, it was written by itself
+		for _, name := range []string{"a/b", "x"} {
+			data, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(name)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != files[name] && string(data) != files[strings.ReplaceAll(name, "/", `\`)] && string(data) != files["../"+name] {
+				t.Errorf("jobs %d: %s has mixed content", jobs, name)
+			}
+		}
+	}
+}
+
+func TestExtractProgress(t *testing.T) {
+	files := make(map[string]string)
+	for i := 0; i < 50; i++ {
+		files[fmt.Sprintf("file%d", i)] = strings.Repeat("x", i*100)
+	}
+	archive := openArchive(t, RPAVersion3, 0xbeef, files, 0)
+
+	var calls, lastDone int
+	var lastBytes int64
+	var running int32
+	onProgress := func(done int, total int, bytes int64) {
+		if !atomic.CompareAndSwapInt32(&running, 0, 1) {
+			t.Error("OnProgress called concurrently")
+		}
+		defer atomic.StoreInt32(&running, 0)
+		time.Sleep(time.Millisecond)
+
+		calls++
+		if done != lastDone+1 || total != len(files) || bytes < lastBytes {
+			t.Errorf("OnProgress(%d, %d, %d) after (%d, %d)", done, total, bytes, lastDone, lastBytes)
+		}
+		lastDone, lastBytes = done, bytes
+	}
+
+	summary, err := ExtractAll(archive, t.TempDir(), ExtractOptions{Jobs: 8, OnProgress: onProgress})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != len(files) || lastDone != len(files) || lastBytes != summary.Bytes {
+		t.Errorf("OnProgress called %d times, last with (%d, %d), want %d calls and %d bytes", calls, lastDone, lastBytes, len(files), summary.Bytes)
+	}
+}
+
+func TestExtractCancel(t *testing.T) {
+	files := make(map[string]string)
+	for i := 0; i < 16; i++ {
+		files[fmt.Sprintf("file%d", i)] = strings.Repeat(fmt.Sprintf("%d,", i), 1<<20)
+	}
+	archive := openArchive(t, RPAVersion3, 0xbeef, files, 0)
+	dest := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// cancel while the other jobs are in the middle of their files
+	onProgress := func(done int, total int, bytes int64) {
+		if done == 1 {
+			cancel()
+		}
+	}
+
+	summary, err := ExtractAllContext(ctx, archive, dest, ExtractOptions{Jobs: 4, OnProgress: onProgress})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want context.Canceled", err)
+	}
+	if summary.Files == len(files) {
+		t.Errorf("extracted all files after canceling")
+	}
+
+	// only completely written files are left
+	extracted := make(map[string]string)
+	entries, err := os.ReadDir(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		extracted[entry.Name()] = files[entry.Name()]
+	}
+	if len(extracted) != summary.Files {
+		t.Errorf("%d files left, summary counts %d", len(extracted), summary.Files)
+	}
+	checkExtracted(t, dest, extracted)
+}
+
+func TestExtractOverwrite(t *testing.T) {
+	files := map[string]string{
+		"same.txt":     "same content",
+		"samesize.txt": "new content!",
+		"diff.txt":     "new and longer content",
+		"old.txt":      "new old",
+		"new.txt":      "new new",
+		"missing.txt":  "missing",
+	}
+	existing := map[string]string{
+		"same.txt":     "same content",
+		"samesize.txt": "old content!",
+		"diff.txt":     "old content",
+		"old.txt":      "stale",
+		"new.txt":      "stale",
+	}
+
+	archiveTime := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		opts    ExtractOptions
+		modTime time.Time
+		// kept are the existing files left as they are
+		kept []string
+	}{
+		{"always", ExtractOptions{Overwrite: OverwriteAlways}, archiveTime, nil},
+		{"never", ExtractOptions{Overwrite: OverwriteNever}, archiveTime, []string{"same.txt", "samesize.txt", "diff.txt", "old.txt", "new.txt"}},
+		{"if-different content", ExtractOptions{Overwrite: OverwriteIfDifferent}, archiveTime, []string{"same.txt"}},
+		{"if-different size", ExtractOptions{Overwrite: OverwriteIfDifferent, Compare: CompareSize}, archiveTime, []string{"same.txt", "samesize.txt"}},
+		{"if-newer", ExtractOptions{Overwrite: OverwriteIfNewer}, archiveTime, []string{"same.txt", "samesize.txt", "diff.txt", "new.txt"}},
+		{"if-newer without time", ExtractOptions{Overwrite: OverwriteIfNewer}, time.Time{}, []string{"same.txt", "samesize.txt", "diff.txt", "old.txt", "new.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := openArchive(t, RPAVersion3, 0xbeef, files, 0)
+			archive.modTime = tt.modTime
+
+			dest := t.TempDir()
+			for name, data := range existing {
+				if err := os.WriteFile(filepath.Join(dest, name), []byte(data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			old := archiveTime.Add(-time.Hour)
+			if err := os.Chtimes(filepath.Join(dest, "old.txt"), old, old); err != nil {
+				t.Fatal(err)
+			}
+
+			summary, err := ExtractAll(archive, dest, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := make(map[string]string)
+			for name, data := range files {
+				want[name] = data
+			}
+			for _, name := range tt.kept {
+				want[name] = existing[name]
+			}
+			checkExtracted(t, dest, want)
+
+			updated := len(existing) - len(tt.kept)
+			if summary.Created != 1 || summary.Updated != updated || summary.Skipped != len(tt.kept) || summary.Files != 1+updated {
+				t.Errorf("summary %s, want 1 created, %d updated, %d skipped", summary, updated, len(tt.kept))
+			}
+		})
+	}
+}
+
+func TestSameContent(t *testing.T) {
+	big := strings.Repeat("0123456789", 20000)
+
+	tests := []struct {
+		name    string
+		onDisk  string
+		archive string
+		want    bool
+	}{
+		{"empty", "", "", true},
+		{"equal", "abc", "abc", true},
+		{"different", "abc", "abd", false},
+		{"shorter on disk", "ab", "abc", false},
+		{"longer on disk", "abcd", "abc", false},
+		{"equal over chunks", big, big, true},
+		{"differ in later chunk", big, big[:len(big)-1] + "x", false},
+		{"chunk size prefix", big[:64*1024], big, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file")
+			if err := os.WriteFile(path, []byte(tt.onDisk), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			same, err := sameContent(path, strings.NewReader(tt.archive))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if same != tt.want {
+				t.Errorf("sameContent = %t, want %t", same, tt.want)
+			}
+		})
+	}
+}
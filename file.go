@@ -0,0 +1,100 @@
+package renpyarchivetool
+
+import (
+	"bytes"
+	"io"
+)
+
+// File is a read-only view of a single file in an archive. It reads directly
+// from the archive, so large files can be streamed without buffering them.
+type File struct {
+	*io.SectionReader
+	name  string
+	index *Index
+}
+
+// Name returns the name of the file inside the archive.
+func (f *File) Name() string {
+	return f.name
+}
+
+// Index returns the index entry the file was opened from.
+func (f *File) Index() *Index {
+	return f.index
+}
+
+// Close is a no-op, the archive handle is shared between all open files.
+func (f *File) Close() error {
+	return nil
+}
+
+var _ interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+} = (*File)(nil)
+
+func newFile(r io.ReaderAt, name string, index *Index) *File {
+	parts := &multiReaderAt{}
+	for _, segment := range index.Segments {
+		if len(segment.Prefix) > 0 {
+			parts.add(io.NewSectionReader(bytes.NewReader(segment.Prefix), 0, int64(len(segment.Prefix))))
+		}
+		parts.add(io.NewSectionReader(r, segment.Offset, segment.Length))
+	}
+
+	return &File{
+		SectionReader: io.NewSectionReader(parts, 0, parts.size),
+		name:          name,
+		index:         index,
+	}
+}
+
+// multiReaderAt is the logical concatenation of several sections.
+type multiReaderAt struct {
+	parts []*io.SectionReader
+	size  int64
+}
+
+func (m *multiReaderAt) add(part *io.SectionReader) {
+	m.parts = append(m.parts, part)
+	m.size += part.Size()
+}
+
+func (m *multiReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	var n int
+	for _, part := range m.parts {
+		if len(p) == 0 {
+			break
+		}
+
+		size := part.Size()
+		if off >= size {
+			off -= size
+			continue
+		}
+
+		want := int64(len(p))
+		if want > size-off {
+			want = size - off
+		}
+
+		numRead, err := part.ReadAt(p[:want], off)
+		n += numRead
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+		if int64(numRead) < want {
+			// the archive is shorter than its index claims
+			return n, io.ErrUnexpectedEOF
+		}
+
+		p = p[numRead:]
+		off = 0
+	}
+
+	if len(p) > 0 {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
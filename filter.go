@@ -0,0 +1,160 @@
+package renpyarchivetool
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-zglob"
+)
+
+// Filter selects files by name and size. Empty fields don't restrict the
+// selection, so the zero Filter, like a nil *Filter, selects all files.
+type Filter struct {
+	// Names selects only files with one of these exact names.
+	Names []string
+	// Include selects only names matching one of these globs. Globs use the
+	// go-zglob syntax, "**" matches across folders and a trailing "/**"
+	// matches everything below a folder. "[" and "]" only match themselves.
+	Include []string
+	// Exclude drops names matching one of these globs.
+	Exclude []string
+	// Regexps selects only names matching one of these expressions.
+	Regexps []*regexp.Regexp
+	// ExcludeRegexps drops names matching one of these expressions.
+	ExcludeRegexps []*regexp.Regexp
+	// MinSize drops files smaller than this.
+	MinSize int64
+	// MaxSize drops files larger than this, no limit if zero.
+	MaxSize int64
+}
+
+// Validate checks that the braces in the globs of the filter are closed and
+// not nested. Match treats invalid globs as not matching anything.
+func (f *Filter) Validate() error {
+	if f == nil {
+		return nil
+	}
+
+	for _, patterns := range [][]string{f.Include, f.Exclude} {
+		for _, pattern := range patterns {
+			if err := checkGlob(pattern); err != nil {
+				return err
+			}
+		}
+	}
+
+	if f.MaxSize > 0 && f.MaxSize < f.MinSize {
+		return fmt.Errorf("max size %d is smaller than min size %d", f.MaxSize, f.MinSize)
+	}
+
+	return nil
+}
+
+// Match reports whether a file with this name and size is selected.
+func (f *Filter) Match(name string, size int64) bool {
+	if f == nil {
+		return true
+	}
+
+	if size < f.MinSize || (f.MaxSize > 0 && size > f.MaxSize) {
+		return false
+	}
+
+	if len(f.Names) > 0 && !containsString(f.Names, name) {
+		return false
+	}
+
+	if len(f.Include) > 0 && !matchGlobs(f.Include, name) {
+		return false
+	}
+
+	if len(f.Regexps) > 0 && !matchRegexps(f.Regexps, name) {
+		return false
+	}
+
+	return !matchGlobs(f.Exclude, name) && !matchRegexps(f.ExcludeRegexps, name)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchGlobs(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, err := matchGlob(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchGlob is zglob.Match, except that a trailing "**" matches names in
+// subfolders too, not only the files directly in the folder.
+func matchGlob(pattern string, name string) (bool, error) {
+	if err := checkGlob(pattern); err != nil {
+		return false, err
+	}
+
+	matched, err := zglob.Match(pattern, name)
+	if err != nil || matched {
+		return matched, err
+	}
+
+	if pattern == "**" {
+		return true, nil
+	}
+
+	if !strings.HasSuffix(pattern, "/**") {
+		return false, nil
+	}
+
+	dir := strings.TrimSuffix(pattern, "/**")
+	for parent := path.Dir(name); parent != "." && parent != "/"; parent = path.Dir(parent) {
+		if matched, err := zglob.Match(dir, parent); err != nil || matched {
+			return matched, err
+		}
+	}
+
+	return false, nil
+}
+
+// checkGlob rejects globs with unclosed or nested braces, which zglob would
+// silently match as something else. A "}" outside of braces matches itself.
+func checkGlob(pattern string) error {
+	open := false
+	for _, c := range pattern {
+		switch {
+		case c == '{' && open:
+			return fmt.Errorf("invalid glob %s. nested {", pattern)
+		case c == '{':
+			open = true
+		case c == '}':
+			open = false
+		}
+	}
+
+	if open {
+		return fmt.Errorf("invalid glob %s. unclosed {", pattern)
+	}
+
+	return nil
+}
+
+func matchRegexps(regexps []*regexp.Regexp, name string) bool {
+	for _, re := range regexps {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,60 @@
+package renpyarchivetool
+
+import "testing"
+
+func TestFilterGlobs(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.rpyc", "script.rpyc", true},
+		{"*.rpyc", "a/script.rpyc", false},
+		{"**/*.rpyc", "a/b/script.rpyc", true},
+		{"images/characters/**", "images/characters/happy.png", true},
+		{"images/characters/**", "images/characters/eileen/happy.png", true},
+		{"images/characters/**", "images/characters", false},
+		{"images/characters/**", "images/charactersX/happy.png", false},
+		{"images/characters/**", "images/bg/room.png", false},
+		{"images/*/**", "images/characters/eileen/happy.png", true},
+		{"images/*/**", "images/room.png", false},
+		{"**", "a/b/c", true},
+		{"{images,audio}/**", "audio/music/main.ogg", true},
+		{"[ab].png", "a.png", false},
+		{"[ab].png", "[ab].png", true},
+		{"x[", "x[", true},
+	}
+
+	for _, tt := range tests {
+		f := &Filter{Include: []string{tt.pattern}}
+		if got := f.Match(tt.name, 0); got != tt.want {
+			t.Errorf("Match(%q) with %q = %t, want %t", tt.name, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestFilterValidate(t *testing.T) {
+	tests := []struct {
+		pattern string
+		valid   bool
+	}{
+		{"images/**", true},
+		{"{a,b}.png", true},
+		{"[ab].png", true},
+		{"[", true},
+		{"a]", true},
+		{"x[", true},
+		{"a}", true},
+		{"{a,b", false},
+		{"{a,{b,c}}", false},
+		{"[{]", false},
+	}
+
+	for _, tt := range tests {
+		for _, f := range []*Filter{{Include: []string{tt.pattern}}, {Exclude: []string{tt.pattern}}} {
+			if err := f.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Validate(%q) = %v, want valid %t", tt.pattern, err, tt.valid)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package renpyarchivetool
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultArchivePattern matches the archives Ren'Py loads.
+const DefaultArchivePattern = "*.rpa"
+
+// DefaultSkipDirs are the folders of a Ren'Py install that only contain the
+// engine and never hold game archives.
+var DefaultSkipDirs = []string{"renpy", "lib"}
+
+// DiscoverOptions controls how DiscoverArchives searches for archives.
+type DiscoverOptions struct {
+	// Recursive searches all subfolders of the root.
+	Recursive bool
+	// Pattern is the glob archives have to match, DefaultArchivePattern if
+	// empty. Patterns containing a slash are matched against the path
+	// relative to the root, support "**" and imply Recursive, other patterns
+	// are matched against the file name.
+	Pattern string
+	// GameDir searches the "game" folder below the root instead of the root
+	// itself if there is one, the way Ren'Py finds its archives.
+	GameDir bool
+	// SkipDirs are names of folders that aren't searched.
+	SkipDirs []string
+}
+
+// FindArchives returns the archives in dir. RPA-1.0 archives are paired
+// with their .rpi index and returned once, by the name of their data file.
+func FindArchives(dir string) ([]string, error) {
+	return DiscoverArchives(dir, DiscoverOptions{})
+}
+
+// DiscoverArchives returns the archives below root selected by opts, sorted.
+// RPA-1.0 archives are paired with their .rpi index and returned once, by the
+// name of their data file.
+func DiscoverArchives(root string, opts DiscoverOptions) ([]string, error) {
+	pattern := opts.Pattern
+	if pattern == "" {
+		pattern = DefaultArchivePattern
+	}
+
+	if opts.GameDir {
+		gameDir := filepath.Join(root, "game")
+		if info, err := os.Stat(gameDir); err == nil && info.IsDir() {
+			root = gameDir
+		}
+	}
+
+	recursive := opts.Recursive || strings.Contains(pattern, "/")
+
+	skipDirs := make(map[string]bool)
+	for _, dir := range opts.SkipDirs {
+		skipDirs[dir] = true
+	}
+
+	seen := make(map[string]bool)
+	archives := make([]string, 0)
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path == root {
+				return nil
+			}
+			if !recursive || skipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		dataFile := path
+		if isLegacyIndexFile(path) {
+			dataFile = legacyDataFileName(path)
+		}
+
+		if seen[dataFile] {
+			return nil
+		}
+
+		// an index is matched by its own name as well as by its data file
+		matched, err := matchArchivePattern(pattern, root, path)
+		if err == nil && !matched && dataFile != path {
+			matched, err = matchArchivePattern(pattern, root, dataFile)
+		}
+		if err != nil || !matched {
+			return err
+		}
+
+		// an index without its data file can't be read
+		if dataFile != path {
+			if _, err := os.Stat(dataFile); err != nil {
+				return nil
+			}
+		}
+
+		seen[dataFile] = true
+		archives = append(archives, dataFile)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(archives)
+
+	return archives, nil
+}
+
+func matchArchivePattern(pattern string, root string, path string) (bool, error) {
+	if !strings.Contains(pattern, "/") {
+		return matchGlob(pattern, filepath.Base(path))
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false, err
+	}
+
+	return matchGlob(pattern, filepath.ToSlash(rel))
+}
@@ -0,0 +1,86 @@
+package renpyarchivetool
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// makeTree creates empty files with the given slash separated names below a
+// new temporary folder.
+func makeTree(t *testing.T, names ...string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for _, name := range names {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func TestDiscoverArchives(t *testing.T) {
+	root := makeTree(t,
+		"a.rpa",
+		"notes.txt",
+		"old.rpa",
+		"old.rpi",
+		"orphan.rpi",
+		"game/images.rpa",
+		"game/sub/deep.rpa",
+		"renpy/common.rpa",
+		"lib/python.rpa",
+	)
+
+	tests := []struct {
+		name string
+		opts DiscoverOptions
+		want []string
+	}{
+		{"flat", DiscoverOptions{}, []string{"a.rpa", "old.rpa"}},
+		{"recursive", DiscoverOptions{Recursive: true}, []string{
+			"a.rpa", "game/images.rpa", "game/sub/deep.rpa", "lib/python.rpa", "old.rpa", "renpy/common.rpa",
+		}},
+		{"skip engine folders", DiscoverOptions{Recursive: true, SkipDirs: DefaultSkipDirs}, []string{
+			"a.rpa", "game/images.rpa", "game/sub/deep.rpa", "old.rpa",
+		}},
+		{"game dir", DiscoverOptions{GameDir: true}, []string{"game/images.rpa"}},
+		{"recursive game dir", DiscoverOptions{GameDir: true, Recursive: true}, []string{"game/images.rpa", "game/sub/deep.rpa"}},
+		{"pattern with slash", DiscoverOptions{Pattern: "game/**/*.rpa"}, []string{"game/images.rpa", "game/sub/deep.rpa"}},
+		{"pattern on file names", DiscoverOptions{Pattern: "images*.rpa", Recursive: true}, []string{"game/images.rpa"}},
+		{"index pattern", DiscoverOptions{Pattern: "*.rpi"}, []string{"old.rpa"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archives, err := DiscoverArchives(root, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, 0, len(archives))
+			for _, archive := range archives {
+				rel, err := filepath.Rel(root, archive)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, filepath.ToSlash(rel))
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := DiscoverArchives(root, DiscoverOptions{Pattern: "{a,b"}); err == nil {
+		t.Error("invalid pattern didn't fail")
+	}
+}
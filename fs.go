@@ -0,0 +1,284 @@
+package renpyarchivetool
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+// FS returns the archive as an fs.FS, which also implements fs.ReadDirFS,
+// fs.StatFS, fs.ReadFileFS and fs.SubFS. Directories are synthesized from
+// the file names in the index. Names that aren't valid fs paths are left out.
+func (rp *RenPyArchive) FS() fs.FS {
+	return &archiveFS{
+		archive: rp,
+		tree:    newFSTree(rp.indexes),
+	}
+}
+
+type fsTree struct {
+	files map[string]*Index
+	dirs  map[string][]fs.DirEntry
+}
+
+func newFSTree(indexes map[string]*Index) *fsTree {
+	t := &fsTree{
+		files: make(map[string]*Index),
+		dirs:  map[string][]fs.DirEntry{".": nil},
+	}
+
+	for name, index := range indexes {
+		if name == "." || !fs.ValidPath(name) {
+			continue
+		}
+		t.files[name] = index
+
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if _, ok := t.dirs[dir]; ok {
+				break
+			}
+			t.dirs[dir] = nil
+		}
+	}
+
+	// a directory hides a file of the same name
+	for dir := range t.dirs {
+		delete(t.files, dir)
+	}
+
+	for name, index := range t.files {
+		parent := path.Dir(name)
+		t.dirs[parent] = append(t.dirs[parent], fs.FileInfoToDirEntry(newFileInfo(name, index)))
+	}
+
+	for dir := range t.dirs {
+		if dir == "." {
+			continue
+		}
+		parent := path.Dir(dir)
+		t.dirs[parent] = append(t.dirs[parent], fs.FileInfoToDirEntry(newDirInfo(dir)))
+	}
+
+	for _, entries := range t.dirs {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Name() < entries[j].Name()
+		})
+	}
+
+	return t
+}
+
+type archiveFS struct {
+	archive *RenPyArchive
+	tree    *fsTree
+	root    string
+}
+
+var _ interface {
+	fs.ReadDirFS
+	fs.StatFS
+	fs.ReadFileFS
+	fs.SubFS
+} = (*archiveFS)(nil)
+
+// resolve maps a name relative to the FS to a name in the archive.
+func (afs *archiveFS) resolve(op string, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	if afs.root == "" {
+		return name, nil
+	}
+
+	if name == "." {
+		return afs.root, nil
+	}
+
+	return afs.root + "/" + name, nil
+}
+
+func (afs *archiveFS) Open(name string) (fs.File, error) {
+	full, err := afs.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if index, ok := afs.tree.files[full]; ok {
+		return &fsFile{
+			File: newFile(afs.archive.handle, full, index),
+			info: newFileInfo(full, index),
+		}, nil
+	}
+
+	if entries, ok := afs.tree.dirs[full]; ok {
+		return &fsDir{
+			info:    newDirInfo(full),
+			entries: entries,
+		}, nil
+	}
+
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (afs *archiveFS) Stat(name string) (fs.FileInfo, error) {
+	full, err := afs.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if index, ok := afs.tree.files[full]; ok {
+		return newFileInfo(full, index), nil
+	}
+
+	if _, ok := afs.tree.dirs[full]; ok {
+		return newDirInfo(full), nil
+	}
+
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+func (afs *archiveFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	full, err := afs.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, ok := afs.tree.dirs[full]
+	if !ok {
+		if _, ok := afs.tree.files[full]; ok {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+		}
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	out := make([]fs.DirEntry, len(entries))
+	copy(out, entries)
+
+	return out, nil
+}
+
+func (afs *archiveFS) ReadFile(name string) ([]byte, error) {
+	full, err := afs.resolve("read", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := afs.tree.files[full]; !ok {
+		if _, ok := afs.tree.dirs[full]; ok {
+			return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+		}
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+
+	data, err := afs.archive.Read(full)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	return data, nil
+}
+
+func (afs *archiveFS) Sub(dir string) (fs.FS, error) {
+	full, err := afs.resolve("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := afs.tree.dirs[full]; !ok {
+		if _, ok := afs.tree.files[full]; ok {
+			return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+		}
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrNotExist}
+	}
+
+	if full == "." {
+		return afs, nil
+	}
+
+	return &archiveFS{
+		archive: afs.archive,
+		tree:    afs.tree,
+		root:    full,
+	}, nil
+}
+
+type fileInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+}
+
+func newFileInfo(name string, index *Index) *fileInfo {
+	return &fileInfo{
+		name: path.Base(name),
+		size: index.Length,
+		mode: 0444,
+	}
+}
+
+func newDirInfo(name string) *fileInfo {
+	return &fileInfo{
+		name: path.Base(name),
+		mode: fs.ModeDir | 0555,
+	}
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return time.Time{} }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+type fsFile struct {
+	*File
+	info *fileInfo
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+type fsDir struct {
+	info    *fileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *fsDir) Close() error {
+	return nil
+}
+
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		out := make([]fs.DirEntry, len(remaining))
+		copy(out, remaining)
+		return out, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	out := make([]fs.DirEntry, n)
+	copy(out, remaining)
+	d.offset += n
+
+	return out, nil
+}
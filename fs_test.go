@@ -0,0 +1,48 @@
+package renpyarchivetool
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFS(t *testing.T) {
+	archive := openArchive(t, RPAVersion3, 0x5eed, testFiles, 2)
+
+	fsys := archive.FS()
+	if err := fstest.TestFS(fsys, "empty", "a.rpy", "script.rpy", "images/bg/room.png", "audio/music/main.ogg"); err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := fs.Sub(fsys, "images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(sub, "bg/room.png"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFSInvalidNames(t *testing.T) {
+	archive := openArchive(t, RPAVersion3, 0, map[string]string{
+		"ok.txt":     "ok",
+		"../up.txt":  "up",
+		"/abs.txt":   "abs",
+		"a//b.txt":   "double slash",
+		"dir":        "hidden by the folder",
+		"dir/in.txt": "in",
+		"trailing/":  "trailing slash",
+		"./dot.txt":  "dot",
+		"x/../y.txt": "dot dot",
+	}, 0)
+
+	if err := fstest.TestFS(archive.FS(), "ok.txt", "dir/in.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"../up.txt", "abs.txt", "a/b.txt", "dot.txt", "y.txt"} {
+		if _, err := fs.Stat(archive.FS(), name); err == nil {
+			t.Errorf("Stat(%q) succeeded, want an error", name)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package renpyarchivetool
+
+import (
+	"compress/zlib"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ArchiveInfo describes the header, index and layout of an archive.
+type ArchiveInfo struct {
+	Version RPAVersion
+	// Scheme is the name of the scheme the archive was decoded with.
+	Scheme string
+	// Header is the raw header line, empty for RPA-1.0 archives.
+	Header string
+	Key    int64
+	Size   int64
+
+	IndexOffset int64
+	// IndexCompressedSize and IndexSize are the size of the index before and
+	// after decompression, zero if it isn't stored inside the archive.
+	IndexCompressedSize int64
+	IndexSize           int64
+	// PickleProtocol is the pickle protocol of the index, zero if unknown.
+	PickleProtocol int
+
+	Entries  int
+	Segments int
+	// PayloadSize is the size of all files, PrefixSize is the part of it
+	// stored in the index.
+	PayloadSize int64
+	PrefixSize  int64
+	// SlackSize is the number of bytes in the data section that no entry
+	// refers to.
+	SlackSize int64
+	Overlaps  []Overlap
+}
+
+// Overlap reports Length bytes at Offset that are used by both entries.
+type Overlap struct {
+	Name      string
+	OtherName string
+	Offset    int64
+	Length    int64
+}
+
+// Info returns information about the archive, useful to inspect unusual or
+// damaged archives.
+func (rp *RenPyArchive) Info() *ArchiveInfo {
+	info := &ArchiveInfo{
+		Version: rp.version,
+		Scheme:  strings.TrimSpace(rp.version.String()),
+		Header:  rp.metadata,
+		Key:     rp.key,
+		Size:    rp.size,
+		Entries: len(rp.indexes),
+	}
+
+	if rp.scheme != nil {
+		info.Scheme = rp.scheme.Name()
+	}
+
+	dataStart, dataEnd := int64(0), rp.size
+	if rp.version != RPAVersion1 {
+		dataStart, dataEnd = rp.headerSize, rp.indexOffset
+		info.IndexOffset = rp.indexOffset
+		info.IndexCompressedSize = rp.size - rp.indexOffset
+
+		rp.indexStats(info)
+	}
+
+	type span struct {
+		name       string
+		start, end int64
+	}
+
+	spans := make([]span, 0, len(rp.indexes))
+	for name, index := range rp.indexes {
+		info.PayloadSize += index.Length
+		info.Segments += len(index.Segments)
+
+		for _, segment := range index.Segments {
+			info.PrefixSize += int64(len(segment.Prefix))
+			if segment.Length > 0 {
+				spans = append(spans, span{name, segment.Offset, segment.Offset + segment.Length})
+			}
+		}
+	}
+
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].name < spans[j].name
+	})
+
+	// sweep over the sorted spans, keeping the spans that haven't ended at
+	// the start of each span to report every pair that overlaps
+	covered, coveredEnd := int64(0), dataStart
+	active := make([]*span, 0)
+	for i := range spans {
+		s := &spans[i]
+
+		stillActive := active[:0]
+		for _, other := range active {
+			if other.end > s.start {
+				stillActive = append(stillActive, other)
+			}
+		}
+		active = stillActive
+
+		for _, other := range active {
+			overlapEnd := s.end
+			if other.end < overlapEnd {
+				overlapEnd = other.end
+			}
+			info.Overlaps = append(info.Overlaps, Overlap{
+				Name:      s.name,
+				OtherName: other.name,
+				Offset:    s.start,
+				Length:    overlapEnd - s.start,
+			})
+		}
+		active = append(active, s)
+
+		start, end := s.start, s.end
+		if start < coveredEnd {
+			start = coveredEnd
+		}
+		if end > dataEnd {
+			end = dataEnd
+		}
+		if end > start {
+			covered += end - start
+			coveredEnd = end
+		}
+	}
+
+	if dataEnd > dataStart {
+		info.SlackSize = dataEnd - dataStart - covered
+	}
+
+	return info
+}
+
+// indexStats fills in the size and pickle protocol of the index. Indexes
+// that aren't plain zlib streams, as used by some custom schemes, are left
+// at zero.
+func (rp *RenPyArchive) indexStats(info *ArchiveInfo) {
+	zlibReader, err := zlib.NewReader(io.NewSectionReader(rp.handle, rp.indexOffset, rp.size-rp.indexOffset))
+	if err != nil {
+		return
+	}
+	defer zlibReader.Close()
+
+	var start [2]byte
+	n, err := io.ReadFull(zlibReader, start[:])
+	if err != nil {
+		return
+	}
+
+	// pickles with protocol 2 or later start with a PROTO opcode
+	if start[0] == pickleProto {
+		info.PickleProtocol = int(start[1])
+	}
+
+	rest, err := io.Copy(io.Discard, zlibReader)
+	if err != nil {
+		return
+	}
+	info.IndexSize = int64(n) + rest
+}
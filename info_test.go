@@ -0,0 +1,74 @@
+package renpyarchivetool
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestInfo(t *testing.T) {
+	archive := openArchive(t, RPAVersion3, 0x42, testFiles, 4)
+	info := archive.Info()
+
+	if info.Version != RPAVersion3 || info.Key != 0x42 || info.Scheme != archive.Scheme().Name() {
+		t.Errorf("info is %s with key %x and scheme %s", info.Version, info.Key, info.Scheme)
+	}
+	if info.Entries != len(testFiles) || info.Segments != len(testFiles) {
+		t.Errorf("info has %d entries and %d segments, want %d", info.Entries, info.Segments, len(testFiles))
+	}
+	if info.PickleProtocol != 2 || info.IndexSize == 0 || info.IndexCompressedSize != info.Size-info.IndexOffset {
+		t.Errorf("index of %d bytes, %d compressed, with pickle protocol %d", info.IndexSize, info.IndexCompressedSize, info.PickleProtocol)
+	}
+	if info.SlackSize != 0 || len(info.Overlaps) != 0 {
+		t.Errorf("written archive has %d bytes slack and overlaps %v", info.SlackSize, info.Overlaps)
+	}
+
+	var payload int64
+	for _, data := range testFiles {
+		payload += int64(len(data))
+	}
+	if info.PayloadSize != payload {
+		t.Errorf("PayloadSize = %d, want %d", info.PayloadSize, payload)
+	}
+}
+
+func TestInfoLayout(t *testing.T) {
+	const header = "RPA-3.0 %016x 00000042\n"
+	h := int64(len(fmt.Sprintf(header, 0)))
+	data := make([]byte, 40)
+
+	indexes := map[string]*Index{
+		"a": NewIndex([]Segment{{Offset: h + 10, Length: 10}}),
+		"b": NewIndex([]Segment{{Offset: h + 15, Length: 15}}),
+		"c": NewIndex([]Segment{{Offset: h + 16, Length: 2, Prefix: []byte("pre")}}),
+		"d": NewIndex([]Segment{{Offset: h + 35, Length: 2}, {Offset: h + 36, Length: 1}}),
+	}
+
+	out := craftArchive(t, header, string(data), indexes, 0x42)
+	archive, err := NewReader(out, int64(len(out.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := archive.Info()
+
+	// [10, 30) and [35, 37) of the 40 bytes are used
+	if info.SlackSize != 18 {
+		t.Errorf("SlackSize = %d, want 18", info.SlackSize)
+	}
+	if info.PrefixSize != 3 || info.Segments != 5 {
+		t.Errorf("PrefixSize = %d and Segments = %d, want 3 and 5", info.PrefixSize, info.Segments)
+	}
+	if info.PickleProtocol != 2 {
+		t.Errorf("PickleProtocol = %d, want 2", info.PickleProtocol)
+	}
+
+	want := []Overlap{
+		{Name: "b", OtherName: "a", Offset: h + 15, Length: 5},
+		{Name: "c", OtherName: "a", Offset: h + 16, Length: 2},
+		{Name: "c", OtherName: "b", Offset: h + 16, Length: 2},
+		{Name: "d", OtherName: "d", Offset: h + 36, Length: 1},
+	}
+	if !reflect.DeepEqual(info.Overlaps, want) {
+		t.Errorf("Overlaps = %+v, want %+v", info.Overlaps, want)
+	}
+}
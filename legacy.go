@@ -0,0 +1,69 @@
+package renpyarchivetool
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RPA-1.0 archives store their data in a .rpa file without a header and the
+// index in a separate .rpi file. Offsets in the index aren't obfuscated.
+
+// NewLegacyReader reads an RPA-1.0 archive from its data and its index.
+func NewLegacyReader(r io.ReaderAt, size int64, index io.Reader) (*RenPyArchive, error) {
+	rp := &RenPyArchive{}
+	if err := rp.initLegacy(r, size, index); err != nil {
+		return nil, err
+	}
+
+	return rp, nil
+}
+
+// LegacyIndexFileName returns the name of the .rpi index file belonging to
+// the given RPA-1.0 data file.
+func LegacyIndexFileName(dataFileName string) string {
+	return strings.TrimSuffix(dataFileName, filepath.Ext(dataFileName)) + ".rpi"
+}
+
+func legacyDataFileName(indexFileName string) string {
+	return strings.TrimSuffix(indexFileName, filepath.Ext(indexFileName)) + ".rpa"
+}
+
+func isLegacyIndexFile(fileName string) bool {
+	return strings.EqualFold(filepath.Ext(fileName), ".rpi")
+}
+
+func (rp *RenPyArchive) loadLegacy(r io.ReaderAt, size int64, indexFileName string) error {
+	index, err := os.Open(indexFileName)
+	if err != nil {
+		return fmt.Errorf("failed to open index file: %s. %w", indexFileName, err)
+	}
+	defer index.Close()
+
+	return rp.initLegacy(r, size, index)
+}
+
+func (rp *RenPyArchive) initLegacy(r io.ReaderAt, size int64, index io.Reader) error {
+	rp.handle = r
+	rp.size = size
+	rp.metadata = ""
+	rp.version = RPAVersion1
+	rp.key = 0
+	rp.headerSize = 0
+	rp.indexOffset = 0
+
+	indexes, err := DecodePickledIndex(index, 0)
+	if err != nil {
+		return fmt.Errorf("failed to extract indexes. %w", err)
+	}
+
+	if err := checkSegments(indexes, 0, size); err != nil {
+		return fmt.Errorf("failed to extract indexes. %w", err)
+	}
+	rp.scheme = nil
+	rp.indexes = indexes
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package renpyarchivetool
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeLegacyArchive writes data.rpa and data.rpi to dir.
+func writeLegacyArchive(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	const data = "helloworld"
+	indexes := map[string]*Index{
+		"hello.txt":     NewIndex([]Segment{{Offset: 0, Length: 5}}),
+		"dir/world.txt": NewIndex([]Segment{{Offset: 5, Length: 5, Prefix: []byte("big ")}}),
+	}
+
+	index, err := compressIndex([]string{"dir/world.txt", "hello.txt"}, indexes, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "data.rpa"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data.rpi"), index, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]string{
+		"hello.txt":     "hello",
+		"dir/world.txt": "big world",
+	}
+}
+
+func TestLegacyArchive(t *testing.T) {
+	dir := t.TempDir()
+	want := writeLegacyArchive(t, dir)
+
+	for _, name := range []string{"data.rpa", "data.rpi"} {
+		t.Run(name, func(t *testing.T) {
+			archive := loadArchive(t, filepath.Join(dir, name))
+			if archive.version != RPAVersion1 {
+				t.Errorf("loaded %s, want RPA-1.0", archive.version)
+			}
+			checkContents(t, archive, want)
+		})
+	}
+
+	t.Run("NewLegacyReader", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join(dir, "data.rpa"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		index, err := os.Open(filepath.Join(dir, "data.rpi"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer index.Close()
+
+		archive, err := NewLegacyReader(bytes.NewReader(data), int64(len(data)), index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkContents(t, archive, want)
+	})
+
+	t.Run("FindArchives", func(t *testing.T) {
+		// an index without its data file is skipped
+		if err := os.WriteFile(filepath.Join(dir, "orphan.rpi"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		archives, err := FindArchives(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{filepath.Join(dir, "data.rpa")}; !reflect.DeepEqual(archives, want) {
+			t.Errorf("FindArchives = %v, want %v", archives, want)
+		}
+	})
+}
@@ -14,11 +14,13 @@ import (
 type renpyArchiveFS struct {
 	gofusefs.Inode
 	archive *renpyarchivetool.RenPyArchive
+	filter  *renpyarchivetool.Filter
 }
 
 // OnAdd implements fs.NodeOnAdder.
 func (r *renpyArchiveFS) OnAdd(ctx context.Context) {
-	for archiveFilePath, info := range r.archive.Indexes() {
+	for _, entry := range r.archive.Entries(renpyarchivetool.WalkOptions{Filter: r.filter}) {
+		archiveFilePath, info := entry.Name, entry.Index
 		dir, base := filepath.Split(archiveFilePath)
 
 		p := r.EmbeddedInode()
@@ -42,18 +44,16 @@ func (r *renpyArchiveFS) OnAdd(ctx context.Context) {
 
 		var attr fuse.Attr
 		attr.Size = uint64(info.Length)
-		data, err := r.archive.Read(archiveFilePath)
-		if err != nil {
-			continue
-		}
-		df := gofusefs.MemRegularFile{
-			Data: data,
+		fileNode := &fuseRenpyArchiveFileNode{
+			archive:  r.archive,
+			filePath: archiveFilePath,
+			info:     info,
+			Attr:     attr,
 		}
 
-		df.Attr = attr
 		p.AddChild(base, r.NewPersistentInode(
 			ctx,
-			&df,
+			fileNode,
 			gofusefs.StableAttr{
 				Mode: syscall.S_IFREG,
 			},
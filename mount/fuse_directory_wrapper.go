@@ -2,9 +2,11 @@ package mount
 
 import (
 	"context"
+	"io"
 	"log"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 
 	gofusefs "github.com/hanwen/go-fuse/v2/fs"
@@ -16,11 +18,17 @@ type fuseDirectoryRootWrapper struct {
 	gofusefs.Inode
 	archiveFileMap map[string]string
 	inodeMap       map[string]*gofusefs.Inode
+	wrappers       []*fuseRenpyArchiveDirectoryWrapper
+	filter         *renpyarchivetool.Filter
 }
 
 type fuseRenpyArchiveDirectoryWrapper struct {
 	gofusefs.Inode
 	archileFilePath string
+	filter          *renpyarchivetool.Filter
+
+	mu      sync.Mutex
+	archive *renpyarchivetool.RenPyArchive
 
 	dirEntries []fuse.DirEntry
 	inodes     []*gofusefs.Inode
@@ -32,8 +40,6 @@ type fuseRenpyArchiveFileNode struct {
 	filePath string
 	Attr     fuse.Attr
 	info     *renpyarchivetool.Index
-
-	data []byte
 }
 
 // Open implements fs.NodeOpener.
@@ -53,20 +59,17 @@ func (f *fuseRenpyArchiveFileNode) Getattr(ctx context.Context, fh gofusefs.File
 var _ = (gofusefs.NodeOpener)((*fuseRenpyArchiveFileNode)(nil))
 
 func (f *fuseRenpyArchiveFileNode) Read(ctx context.Context, fh gofusefs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
-	if f.data == nil {
-		data, err := f.archive.Read(f.filePath)
-		if err != nil {
-			return nil, syscall.EIO
-		}
-		f.data = data
+	file, err := f.archive.Open(f.filePath)
+	if err != nil {
+		return nil, syscall.EIO
 	}
 
-	end := off + int64(len(dest))
-	if end > int64(len(f.data)) {
-		end = int64(len(f.data))
+	numRead, err := file.ReadAt(dest, off)
+	if err != nil && err != io.EOF {
+		return nil, syscall.EIO
 	}
 
-	return fuse.ReadResultData(f.data[off:end]), gofusefs.OK
+	return fuse.ReadResultData(dest[:numRead]), gofusefs.OK
 }
 
 type fuseRenpyArchiveDirNode struct {
@@ -78,6 +81,9 @@ type fuseRenpyArchiveDirNode struct {
 
 // Readdir implements fs.NodeReaddirer.
 func (f *fuseRenpyArchiveDirectoryWrapper) Readdir(ctx context.Context) (gofusefs.DirStream, syscall.Errno) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	if f.dirEntries != nil {
 		return gofusefs.NewListDirStream(f.dirEntries), gofusefs.OK
 	}
@@ -87,8 +93,10 @@ func (f *fuseRenpyArchiveDirectoryWrapper) Readdir(ctx context.Context) (gofusef
 	if err != nil {
 		return nil, syscall.EIO
 	}
+	f.archive = archive
 
-	for archiveFilePath, info := range archive.Indexes() {
+	for _, entry := range archive.Entries(renpyarchivetool.WalkOptions{Filter: f.filter}) {
+		archiveFilePath, info := entry.Name, entry.Index
 		p := f.EmbeddedInode()
 
 		dir, base := filepath.Split(archiveFilePath)
@@ -198,6 +206,21 @@ func (f *fuseRenpyArchiveDirectoryWrapper) Readdir(ctx context.Context) (gofusef
 
 var _ gofusefs.NodeReaddirer = (*fuseRenpyArchiveDirectoryWrapper)(nil)
 
+// Close closes the archive if it has been opened.
+func (f *fuseRenpyArchiveDirectoryWrapper) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.archive == nil {
+		return nil
+	}
+
+	err := f.archive.Close()
+	f.archive = nil
+
+	return err
+}
+
 func NewFuseDirectoryWrapper(archiveFiles []string) *fuseDirectoryRootWrapper {
 
 	archiveFileMap := make(map[string]string)
@@ -213,7 +236,7 @@ func NewFuseDirectoryWrapper(archiveFiles []string) *fuseDirectoryRootWrapper {
 				panic("duplicate file name for: " + archiveFilePath)
 			}
 			var newbase string
-			dir, newbase = filepath.Split(dir)
+			dir, newbase = filepath.Split(strings.TrimSuffix(dir, string(filepath.Separator)))
 			base = newbase + "_" + base
 		}
 	}
@@ -232,6 +255,7 @@ func (r *fuseDirectoryRootWrapper) OnAdd(ctx context.Context) {
 		if child == nil {
 			rf := &fuseRenpyArchiveDirectoryWrapper{
 				archileFilePath: archileFilePath,
+				filter:          r.filter,
 			}
 			/*
 				var attr fuse.Attr
@@ -249,7 +273,22 @@ func (r *fuseDirectoryRootWrapper) OnAdd(ctx context.Context) {
 
 			if !success {
 				log.Println("failed to add child", mountPath)
+				continue
 			}
+
+			r.wrappers = append(r.wrappers, rf)
 		}
 	}
 }
+
+// Close closes all archives opened while the directory was mounted.
+func (r *fuseDirectoryRootWrapper) Close() error {
+	var firstErr error
+	for _, wrapper := range r.wrappers {
+		if err := wrapper.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
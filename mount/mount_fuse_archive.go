@@ -40,6 +40,15 @@ func Archive(
 	mountPath string,
 	archivePath string,
 ) (Controller, error) {
+	return ArchiveWithOptions(mountPath, archivePath, Options{})
+}
+
+// ArchiveWithOptions mounts the files of an archive selected by opts.
+func ArchiveWithOptions(
+	mountPath string,
+	archivePath string,
+	opts Options,
+) (Controller, error) {
 
 	if mountPath == "." {
 		_, fileName := filepath.Split(archivePath)
@@ -61,9 +70,10 @@ func Archive(
 		return nil, err
 	}
 
-	_ = archive
-
-	rootfs := NewRenpyArchiveFS(archive)
+	rootfs := &renpyArchiveFS{
+		archive: archive,
+		filter:  opts.Filter,
+	}
 
 	fuseServer, err := gofusefs.Mount(mountPath, rootfs, &gofusefs.Options{
 		MountOptions: fuse.MountOptions{
@@ -78,12 +88,14 @@ func Archive(
 	})
 
 	if err != nil {
+		archive.Close()
 		return nil, err
 	}
 
 	done := make(chan struct{})
 	go func() {
 		fuseServer.Wait()
+		archive.Close()
 		close(done)
 	}()
 
@@ -5,21 +5,29 @@ import (
 
 	gofusefs "github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
-	"github.com/mattn/go-zglob"
+	"github.com/tw1nk/renpyarchivetool"
 )
 
 func Directory(mountPath string, dirPath string) (Controller, error) {
+	return DirectoryWithOptions(mountPath, dirPath, Options{})
+}
+
+// DirectoryWithOptions mounts the archives below dirPath selected by opts.
+// Archives with the same name are told apart by prefixing the folders they
+// are in.
+func DirectoryWithOptions(mountPath string, dirPath string, opts Options) (Controller, error) {
 	dirPath, err := filepath.Abs(dirPath)
 	if err != nil {
 		return nil, err
 	}
 
-	files, err := zglob.Glob(filepath.Join(dirPath, "*.rpa"))
+	files, err := renpyarchivetool.DiscoverArchives(dirPath, opts.Discover)
 	if err != nil {
 		return nil, err
 	}
 
 	rootfs := NewFuseDirectoryWrapper(files)
+	rootfs.filter = opts.Filter
 
 	fuseServer, err := gofusefs.Mount(mountPath, rootfs, &gofusefs.Options{
 		MountOptions: fuse.MountOptions{
@@ -40,6 +48,7 @@ func Directory(mountPath string, dirPath string) (Controller, error) {
 	done := make(chan struct{})
 	go func() {
 		fuseServer.Wait()
+		rootfs.Close()
 		close(done)
 	}()
 
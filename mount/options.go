@@ -0,0 +1,11 @@
+package mount
+
+import "github.com/tw1nk/renpyarchivetool"
+
+// Options controls what ArchiveWithOptions and DirectoryWithOptions mount.
+type Options struct {
+	// Discover selects the archives mounted by DirectoryWithOptions.
+	Discover renpyarchivetool.DiscoverOptions
+	// Filter selects the files shown of each archive, all files if nil.
+	Filter *renpyarchivetool.Filter
+}
@@ -0,0 +1,150 @@
+package renpyarchivetool
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// OverwritePolicy decides what ExtractAll does with files that already exist
+// in the destination.
+type OverwritePolicy int
+
+const (
+	// OverwriteAlways replaces existing files.
+	OverwriteAlways OverwritePolicy = iota
+	// OverwriteNever keeps existing files.
+	OverwriteNever
+	// OverwriteIfDifferent replaces existing files that differ from the file
+	// in the archive, as decided by the CompareMode.
+	OverwriteIfDifferent
+	// OverwriteIfNewer replaces existing files older than the archive. Archives
+	// without a modification time, like those from NewReader, replace
+	// nothing, as no file can be shown to be older.
+	OverwriteIfNewer
+)
+
+func (p OverwritePolicy) String() string {
+	switch p {
+	case OverwriteAlways:
+		return "always"
+	case OverwriteNever:
+		return "never"
+	case OverwriteIfDifferent:
+		return "if-different"
+	case OverwriteIfNewer:
+		return "if-newer"
+	}
+
+	return "<unknown>"
+}
+
+// ParseOverwritePolicy parses the name of a policy as returned by String.
+func ParseOverwritePolicy(s string) (OverwritePolicy, error) {
+	for _, p := range []OverwritePolicy{OverwriteAlways, OverwriteNever, OverwriteIfDifferent, OverwriteIfNewer} {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+
+	return OverwriteAlways, fmt.Errorf("unknown overwrite policy: %s", s)
+}
+
+// CompareMode decides how OverwriteIfDifferent compares files.
+type CompareMode int
+
+const (
+	// CompareContent compares the size and then the content of the files.
+	CompareContent CompareMode = iota
+	// CompareSize only compares the size of the files, which is much faster
+	// but misses changes that keep the size.
+	CompareSize
+)
+
+type extractResult int
+
+const (
+	extractCreated extractResult = iota
+	extractUpdated
+	extractSkipped
+)
+
+// checkExisting decides whether file has to be written to outputPath.
+// modTime is the modification time of the archive, zero if unknown.
+func checkExisting(outputPath string, file *File, modTime time.Time, opts ExtractOptions) (extractResult, error) {
+	info, err := os.Stat(outputPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return extractCreated, nil
+	}
+	if err != nil {
+		return extractCreated, err
+	}
+
+	switch opts.Overwrite {
+	case OverwriteNever:
+		return extractSkipped, nil
+
+	case OverwriteIfNewer:
+		// without a time to compare to, the file isn't known to be older
+		if modTime.IsZero() || !info.ModTime().Before(modTime) {
+			return extractSkipped, nil
+		}
+
+	case OverwriteIfDifferent:
+		if !info.Mode().IsRegular() || info.Size() != file.Size() {
+			break
+		}
+
+		if opts.Compare == CompareSize {
+			return extractSkipped, nil
+		}
+
+		same, err := sameContent(outputPath, file)
+		if err != nil {
+			return extractUpdated, err
+		}
+		if same {
+			return extractSkipped, nil
+		}
+	}
+
+	return extractUpdated, nil
+}
+
+// sameContent reports whether the file at path has the same content as r.
+func sameContent(path string, r io.Reader) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	const chunkSize = 64 * 1024
+	bufA, bufB := make([]byte, chunkSize), make([]byte, chunkSize)
+
+	for {
+		nA, errA := io.ReadFull(f, bufA)
+		nB, errB := io.ReadFull(r, bufB)
+
+		if !bytes.Equal(bufA[:nA], bufB[:nB]) {
+			return false, nil
+		}
+
+		endA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		endB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		if errA != nil && !endA {
+			return false, errA
+		}
+		if errB != nil && !endB {
+			return false, errB
+		}
+
+		if endA || endB {
+			return endA == endB, nil
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package renpyarchivetool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// pickle opcodes used by the index encoder.
+const (
+	pickleMark        = '('
+	pickleStop        = '.'
+	pickleBinInt      = 'J'
+	pickleBinInt1     = 'K'
+	pickleBinInt2     = 'M'
+	pickleBinUnicode  = 'X'
+	pickleEmptyDict   = '}'
+	pickleSetItems    = 'u'
+	pickleEmptyList   = ']'
+	pickleAppends     = 'e'
+	pickleShortBinStr = 'U'
+	pickleBinStr      = 'T'
+	pickleProto       = '\x80'
+	pickleLong1       = '\x8a'
+	pickleTuple3      = '\x87'
+)
+
+// pickleEncoder is a minimal pickle writer supporting only the types needed
+// to store a Ren'Py archive index.
+type pickleEncoder struct {
+	buf bytes.Buffer
+}
+
+func (e *pickleEncoder) op(op byte) {
+	e.buf.WriteByte(op)
+}
+
+func (e *pickleEncoder) proto(version byte) {
+	e.buf.WriteByte(pickleProto)
+	e.buf.WriteByte(version)
+}
+
+func (e *pickleEncoder) int(v int64) {
+	switch {
+	case v >= 0 && v <= math.MaxUint8:
+		e.buf.WriteByte(pickleBinInt1)
+		e.buf.WriteByte(byte(v))
+	case v >= 0 && v <= math.MaxUint16:
+		e.buf.WriteByte(pickleBinInt2)
+		_ = binary.Write(&e.buf, binary.LittleEndian, uint16(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		e.buf.WriteByte(pickleBinInt)
+		_ = binary.Write(&e.buf, binary.LittleEndian, int32(v))
+	default:
+		e.long(v)
+	}
+}
+
+// long writes v as a LONG1, the little-endian two's complement encoding
+// using the smallest number of bytes that preserves the sign.
+func (e *pickleEncoder) long(v int64) {
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, uint64(v))
+
+	for len(data) > 1 {
+		last, prev := data[len(data)-1], data[len(data)-2]
+		if (last == 0x00 && prev&0x80 == 0) || (last == 0xff && prev&0x80 != 0) {
+			data = data[:len(data)-1]
+			continue
+		}
+		break
+	}
+
+	e.buf.WriteByte(pickleLong1)
+	e.buf.WriteByte(byte(len(data)))
+	e.buf.Write(data)
+}
+
+func (e *pickleEncoder) unicode(s string) {
+	e.buf.WriteByte(pickleBinUnicode)
+	_ = binary.Write(&e.buf, binary.LittleEndian, uint32(len(s)))
+	e.buf.WriteString(s)
+}
+
+// bytes writes b as a Python 2 str, the bytes opcodes only exist from
+// protocol 3 on and can't be read by Python 2 based Ren'Py versions.
+func (e *pickleEncoder) bytes(b []byte) {
+	if len(b) <= math.MaxUint8 {
+		e.buf.WriteByte(pickleShortBinStr)
+		e.buf.WriteByte(byte(len(b)))
+	} else {
+		e.buf.WriteByte(pickleBinStr)
+		_ = binary.Write(&e.buf, binary.LittleEndian, uint32(len(b)))
+	}
+	e.buf.Write(b)
+}
+
+// pickleIndex encodes the archive index the way Ren'Py stores it: a dict
+// mapping each file name to a list of (offset, length, prefix) segments, with
+// offset and length XORed by key. names controls the order of the entries.
+func pickleIndex(names []string, indexes map[string]*Index, key int64) []byte {
+	e := &pickleEncoder{}
+	e.proto(2)
+	e.op(pickleEmptyDict)
+	e.op(pickleMark)
+
+	for _, name := range names {
+		index := indexes[name]
+
+		e.unicode(name)
+		e.op(pickleEmptyList)
+		e.op(pickleMark)
+		for _, segment := range index.Segments {
+			e.int(segment.Offset ^ key)
+			e.int(segment.Length ^ key)
+			e.bytes(segment.Prefix)
+			e.op(pickleTuple3)
+		}
+		e.op(pickleAppends)
+	}
+
+	e.op(pickleSetItems)
+	e.op(pickleStop)
+
+	return e.buf.Bytes()
+}
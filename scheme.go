@@ -0,0 +1,331 @@
+package renpyarchivetool
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nlpodyssey/gopickle/pickle"
+	"github.com/nlpodyssey/gopickle/types"
+)
+
+// Scheme describes an archive format identified by its header line. Games
+// that ship a modified Ren'Py archiver can be supported by registering a
+// Scheme for their format.
+type Scheme interface {
+	// Name returns a short name for the scheme, such as "RPA-3.0".
+	Name() string
+
+	// Detect reports whether the header line, without the trailing newline,
+	// belongs to an archive of this scheme.
+	Detect(header []byte) bool
+
+	// DecodeIndex reads the index of the archive r of the given size.
+	DecodeIndex(header []byte, r io.ReaderAt, size int64) (*DecodedIndex, error)
+}
+
+// DecodedIndex is the result of decoding an archive index.
+type DecodedIndex struct {
+	// Version is the closest RPAVersion of the archive, RPAVersionUnknown
+	// for custom formats.
+	Version RPAVersion
+	// Offset is where the index is stored in the archive.
+	Offset int64
+	// Key is the key offsets and lengths were obfuscated with.
+	Key int64
+
+	Indexes map[string]*Index
+}
+
+var (
+	schemesMu sync.RWMutex
+	schemes   []Scheme
+)
+
+// RegisterScheme adds a scheme used to detect and decode archives. Schemes
+// registered later take precedence, so a scheme can override the built-in
+// handling of a header.
+func RegisterScheme(scheme Scheme) {
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+
+	schemes = append([]Scheme{scheme}, schemes...)
+}
+
+// Schemes returns the registered schemes in the order they are tried.
+func Schemes() []Scheme {
+	schemesMu.RLock()
+	defer schemesMu.RUnlock()
+
+	out := make([]Scheme, len(schemes))
+	copy(out, schemes)
+
+	return out
+}
+
+func detectScheme(header []byte) Scheme {
+	for _, scheme := range Schemes() {
+		if scheme.Detect(header) {
+			return scheme
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	RegisterScheme(&rpaScheme{version: RPAVersion2, minFields: 2, maxFields: 2})
+	RegisterScheme(&rpaScheme{version: RPAVersion3, minFields: 3, keyField: 2})
+	RegisterScheme(&rpaScheme{version: RPAVersion32, minFields: 4, keyField: 3})
+	RegisterScheme(&rpaScheme{version: RPAVersion4, minFields: 3, keyField: 2})
+	RegisterScheme(&alt1Scheme{})
+}
+
+// rpaScheme handles the standard "RPA-x.y <offset> [<key>...]" headers. The
+// keys from keyField on are XORed together, fields between the offset and
+// keyField must be hex but are otherwise ignored.
+type rpaScheme struct {
+	version   RPAVersion
+	minFields int
+	maxFields int
+	keyField  int
+}
+
+func (s *rpaScheme) Name() string {
+	return strings.TrimSpace(s.version.String())
+}
+
+func (s *rpaScheme) Detect(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(s.version.String()))
+}
+
+func (s *rpaScheme) DecodeIndex(header []byte, r io.ReaderAt, size int64) (*DecodedIndex, error) {
+	vals := strings.Fields(string(header))
+	if len(vals) < s.minFields || (s.maxFields > 0 && len(vals) > s.maxFields) {
+		if s.minFields == s.maxFields {
+			return nil, fmt.Errorf("%w: %s header expects %d fields, got %d", ErrInvalidHeader, s.Name(), s.minFields, len(vals))
+		}
+		return nil, fmt.Errorf("%w: %s header expects at least %d fields, got %d", ErrInvalidHeader, s.Name(), s.minFields, len(vals))
+	}
+
+	offset, err := parseHexField(vals[1], "index offset")
+	if err != nil {
+		return nil, err
+	}
+
+	var key int64
+	if s.keyField > 0 {
+		for _, field := range vals[2:s.keyField] {
+			if _, err := parseHexField(field, "field"); err != nil {
+				return nil, err
+			}
+		}
+
+		for i, subKeyString := range vals[s.keyField:] {
+			subKey, err := parseHexField(subKeyString, fmt.Sprintf("subkey %d", i))
+			if err != nil {
+				return nil, err
+			}
+			key ^= subKey
+		}
+	}
+
+	return decodeIndexAt(s.version, header, r, size, offset, key)
+}
+
+// alt1Scheme handles "ALT-1.0 <key> <offset>" headers, where the key is
+// additionally XORed with a fixed value.
+type alt1Scheme struct{}
+
+const alt1ExtraKey = 0xDABE8DF0
+
+func (s *alt1Scheme) Name() string {
+	return strings.TrimSpace(ALT1Magic)
+}
+
+func (s *alt1Scheme) Detect(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(ALT1Magic))
+}
+
+func (s *alt1Scheme) DecodeIndex(header []byte, r io.ReaderAt, size int64) (*DecodedIndex, error) {
+	vals := strings.Fields(string(header))
+	if len(vals) != 3 {
+		return nil, fmt.Errorf("%w: %s header expects 3 fields, got %d", ErrInvalidHeader, s.Name(), len(vals))
+	}
+
+	key, err := parseHexField(vals[1], "key")
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := parseHexField(vals[2], "index offset")
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeIndexAt(RPAVersionALT1, header, r, size, offset, key^alt1ExtraKey)
+}
+
+func parseHexField(field string, name string) (int64, error) {
+	v, err := strconv.ParseInt(field, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to parse %s %q. %v", ErrInvalidHeader, name, field, err)
+	}
+
+	return v, nil
+}
+
+// decodeIndexAt validates the index offset against the header and archive
+// size, then decodes the pickled index stored there.
+func decodeIndexAt(version RPAVersion, header []byte, r io.ReaderAt, size int64, offset int64, key int64) (*DecodedIndex, error) {
+	headerSize := int64(len(header)) + 1
+	if offset < headerSize {
+		return nil, fmt.Errorf("%w: index offset %d overlaps the %d byte header", ErrInvalidHeader, offset, headerSize)
+	}
+	if offset >= size {
+		return nil, fmt.Errorf("%w: index offset %d is beyond the end of the archive (%d bytes)", ErrInvalidHeader, offset, size)
+	}
+
+	indexes, err := DecodePickledIndex(io.NewSectionReader(r, offset, size-offset), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DecodedIndex{
+		Version: version,
+		Offset:  offset,
+		Key:     key,
+		Indexes: indexes,
+	}, nil
+}
+
+// DecodePickledIndex reads a zlib compressed, pickled index from r, the way
+// Ren'Py stores it, and XORs offsets and lengths with xorKey.
+func DecodePickledIndex(r io.Reader, xorKey int64) (map[string]*Index, error) {
+	zlibReader, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptIndex, err)
+	}
+	defer zlibReader.Close()
+
+	unpickler := pickle.NewUnpickler(zlibReader)
+	unpickled, err := unpickler.Load()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptIndex, err)
+	}
+
+	obfuscatedIndexs, ok := unpickled.(*types.Dict)
+	if !ok {
+		return nil, fmt.Errorf("%w: unpicked data not *types.Dict. was: %T", ErrCorruptIndex, unpickled)
+	}
+
+	indexes := make(map[string]*Index)
+
+	for _, keyInterface := range obfuscatedIndexs.Keys() {
+		key, ok := keyInterface.(string)
+		if !ok {
+			return nil, &IndexError{Key: fmt.Sprint(keyInterface), Err: fmt.Errorf("key wasn't a string. it's a %T", keyInterface)}
+		}
+
+		interfaceValue, ok := obfuscatedIndexs.Get(key)
+		if !ok {
+			return nil, &IndexError{Key: key, Err: errors.New("key didn't exist in dict")}
+		}
+		value, ok := interfaceValue.(*types.List)
+		if !ok {
+			return nil, &IndexError{Key: key, Err: fmt.Errorf("value wasn't *types.List. was: %T", interfaceValue)}
+		}
+
+		if value.Len() == 0 {
+			return nil, &IndexError{Key: key, Err: errors.New("value has no segments")}
+		}
+
+		segments := make([]Segment, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			segment, err := decodeSegment(value.Get(i), xorKey)
+			if err != nil {
+				return nil, &IndexError{Key: key, Err: fmt.Errorf("segment %d: %w", i, err)}
+			}
+			segments = append(segments, segment)
+		}
+
+		indexes[key] = NewIndex(segments)
+	}
+
+	return indexes, nil
+}
+
+// checkSegments reports an *IndexError for the first segment, by file name,
+// that isn't stored between dataStart and the end of an archive of the given
+// size, so no read can go past the archive or allocate more than it holds.
+func checkSegments(indexes map[string]*Index, dataStart int64, size int64) error {
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for i, segment := range indexes[name].Segments {
+			var err error
+			switch {
+			case segment.Offset < dataStart:
+				err = fmt.Errorf("offset %d is before the data section at %d", segment.Offset, dataStart)
+			case segment.Length < 0:
+				err = fmt.Errorf("length %d is negative", segment.Length)
+			case segment.Offset > size || segment.Length > size-segment.Offset:
+				err = fmt.Errorf("%d bytes at offset %d go past the end of the archive (%d bytes)", segment.Length, segment.Offset, size)
+			}
+			if err != nil {
+				return &IndexError{Key: name, Err: fmt.Errorf("segment %d: %w", i, err)}
+			}
+		}
+	}
+
+	return nil
+}
+
+func decodeSegment(item interface{}, xorKey int64) (Segment, error) {
+	indexData, ok := item.(*types.Tuple)
+	if !ok {
+		return Segment{}, fmt.Errorf("wasn't *types.Tuple. was: %T", item)
+	}
+
+	if indexData.Len() < 2 {
+		return Segment{}, fmt.Errorf("expected at least 2 values, got %d", indexData.Len())
+	}
+
+	indexOffset, ok := indexData.Get(0).(int)
+	if !ok {
+		return Segment{}, fmt.Errorf("indexOffset wasn't int. was: %T", indexData.Get(0))
+	}
+	length, ok := indexData.Get(1).(int)
+	if !ok {
+		return Segment{}, fmt.Errorf("length wasn't int. was: %T", indexData.Get(1))
+	}
+
+	segment := Segment{
+		Offset: int64(indexOffset) ^ xorKey,
+		Length: int64(length) ^ xorKey,
+	}
+
+	if indexData.Len() > 2 {
+		prefix, ok := indexData.Get(2).(string)
+		if !ok {
+			prefixBytes, ok := indexData.Get(2).([]byte)
+			if !ok {
+				return Segment{}, fmt.Errorf("prefix not a string or []byte. %T", indexData.Get(2))
+			}
+			prefix = string(prefixBytes)
+		}
+		segment.Prefix = []byte(prefix)
+	}
+
+	return segment, nil
+}
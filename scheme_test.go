@@ -0,0 +1,256 @@
+package renpyarchivetool
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// craftArchive builds an archive by hand with header, which gets the index
+// offset as %d argument, so headers the Writer can't produce can be tested.
+func craftArchive(t *testing.T, header string, data string, indexes map[string]*Index, xorKey int64) *memFile {
+	t.Helper()
+
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+
+	index, err := compressIndex(names, indexes, xorKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headerSize := int64(len(fmt.Sprintf(header, 0)))
+	indexOffset := headerSize + int64(len(data))
+
+	out := &memFile{}
+	io.WriteString(out, fmt.Sprintf(header, indexOffset))
+	if int64(len(out.data)) != headerSize {
+		t.Fatalf("header %q changes size with the index offset", header)
+	}
+	io.WriteString(out, data)
+	out.Write(index)
+
+	return out
+}
+
+func TestSchemes(t *testing.T) {
+	const data = "hello world"
+
+	tests := []struct {
+		name    string
+		header  string
+		xorKey  int64
+		version RPAVersion
+		key     int64
+	}{
+		{"RPA-2.0", "RPA-2.0 %016x\n", 0, RPAVersion2, 0},
+		{"RPA-3.0", "RPA-3.0 %016x 0000abcd\n", 0xabcd, RPAVersion3, 0xabcd},
+		{"RPA-3.0 split key", "RPA-3.0 %016x 0000ab00 000000cd\n", 0xabcd, RPAVersion3, 0xabcd},
+		{"RPA-3.2", "RPA-3.2 %016x 00000000 deadbeef\n", 0xdeadbeef, RPAVersion32, 0xdeadbeef},
+		{"RPA-4.0", "RPA-4.0 %016x 00001234\n", 0x1234, RPAVersion4, 0x1234},
+		{"ALT-1.0", "ALT-1.0 " + fmt.Sprintf("%08x", 0x1234^0xDABE8DF0) + " %016x\n", 0x1234, RPAVersionALT1, 0x1234},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headerSize := int64(len(fmt.Sprintf(tt.header, 0)))
+			indexes := map[string]*Index{
+				"hello.txt": NewIndex([]Segment{{Offset: headerSize, Length: 5}}),
+				"world.txt": NewIndex([]Segment{{Offset: headerSize + 6, Length: 5, Prefix: []byte("big ")}}),
+			}
+
+			out := craftArchive(t, tt.header, data, indexes, tt.xorKey)
+			archive, err := NewReader(out, int64(len(out.data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if archive.version != tt.version || archive.key != tt.key {
+				t.Errorf("read %s with key %x, want %s with key %x", archive.version, archive.key, tt.version, tt.key)
+			}
+
+			checkContents(t, archive, map[string]string{
+				"hello.txt": "hello",
+				"world.txt": "big world",
+			})
+		})
+	}
+}
+
+func TestHeaderErrors(t *testing.T) {
+	tests := []struct {
+		header string
+		want   error
+	}{
+		{"", ErrNotArchive},
+		{"PK\x03\x04", ErrNotArchive},
+		{"RPA-9.9 0000000000000020", ErrUnsupportedVersion},
+
+		{"RPA-2.0", ErrInvalidHeader},
+		{"RPA-2.0 zz", ErrInvalidHeader},
+		{"RPA-2.0 0000000000000030 extra", ErrInvalidHeader},
+		{"RPA-2.0 0000000000000010", ErrInvalidHeader},
+		{"RPA-2.0 0000000000001000", ErrInvalidHeader},
+
+		{"RPA-3.0 0000000000000030", ErrInvalidHeader},
+		{"RPA-3.0 0000000000000030 xyz", ErrInvalidHeader},
+		{"RPA-3.0 00000000000000zz deadbeef", ErrInvalidHeader},
+		{"RPA-3.0 0000000000000001 deadbeef", ErrInvalidHeader},
+
+		{"RPA-3.2 0000000000000030 deadbeef", ErrInvalidHeader},
+		{"RPA-3.2 0000000000000030 00000000 xyz", ErrInvalidHeader},
+
+		{"RPA-4.0 0000000000000030", ErrInvalidHeader},
+		{"RPA-4.0 0000000000000030 xyz", ErrInvalidHeader},
+
+		{"ALT-1.0", ErrInvalidHeader},
+		{"ALT-1.0 xyz 0000000000000030", ErrInvalidHeader},
+		{"ALT-1.0 deadbeef zz", ErrInvalidHeader},
+
+		// a valid header pointing to data that isn't an index
+		{"RPA-3.0 0000000000000024 deadbeef", ErrCorruptIndex},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			data := []byte(tt.header + "\n" + strings.Repeat("\x00", 64))
+			_, err := NewReader(&memFile{data: data}, int64(len(data)))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegmentErrors(t *testing.T) {
+	const header = "RPA-3.0 %016x deadbeef\n"
+	const key = 0xdeadbeef
+	const data = "some file data"
+	headerSize := int64(len(fmt.Sprintf(header, 0)))
+
+	tests := []struct {
+		name    string
+		segment Segment
+	}{
+		{"huge length", Segment{Offset: headerSize, Length: 1 << 60}},
+		{"32-bit length", Segment{Offset: headerSize, Length: 0xffffffff}},
+		{"negative length", Segment{Offset: headerSize, Length: -1}},
+		{"negative offset", Segment{Offset: -5, Length: 1}},
+		{"offset in header", Segment{Offset: 3, Length: 1}},
+		{"past the end", Segment{Offset: headerSize + 10, Length: 1000}},
+		{"offset past the end", Segment{Offset: 1 << 40, Length: 0}},
+		{"overflowing end", Segment{Offset: headerSize, Length: 1<<63 - 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indexes := map[string]*Index{
+				"good.txt": NewIndex([]Segment{{Offset: headerSize, Length: 4}}),
+				"bad.txt":  NewIndex([]Segment{{Offset: headerSize, Length: 2}, tt.segment}),
+			}
+
+			out := craftArchive(t, header, data, indexes, key)
+			_, err := NewReader(out, int64(len(out.data)))
+
+			var indexErr *IndexError
+			if !errors.As(err, &indexErr) || !errors.Is(err, ErrCorruptIndex) {
+				t.Fatalf("got error %v, want an *IndexError", err)
+			}
+			if indexErr.Key != "bad.txt" {
+				t.Errorf("error is about %s, want bad.txt", indexErr.Key)
+			}
+		})
+	}
+}
+
+// testScheme reads "<magic> <offset>" headers with a fixed key, like a game
+// with a modified archiver would.
+type testScheme struct {
+	name  string
+	magic string
+	key   int64
+}
+
+func (s *testScheme) Name() string {
+	return s.name
+}
+
+func (s *testScheme) Detect(header []byte) bool {
+	return strings.HasPrefix(string(header), s.magic+" ")
+}
+
+func (s *testScheme) DecodeIndex(header []byte, r io.ReaderAt, size int64) (*DecodedIndex, error) {
+	offset, err := strconv.ParseInt(strings.Fields(string(header))[1], 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+
+	indexes, err := DecodePickledIndex(io.NewSectionReader(r, offset, size-offset), s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DecodedIndex{Version: RPAVersionUnknown, Offset: offset, Key: s.key, Indexes: indexes}, nil
+}
+
+func TestRegisterScheme(t *testing.T) {
+	schemesMu.Lock()
+	saved := schemes
+	schemesMu.Unlock()
+	t.Cleanup(func() {
+		schemesMu.Lock()
+		schemes = saved
+		schemesMu.Unlock()
+	})
+
+	const header = "XYZ-1.0 %016x\n"
+	headerSize := int64(len(fmt.Sprintf(header, 0)))
+	indexes := map[string]*Index{
+		"custom.txt": NewIndex([]Segment{{Offset: headerSize, Length: 6}}),
+	}
+	out := craftArchive(t, header, "custom", indexes, 0x77)
+
+	if _, err := NewReader(out, int64(len(out.data))); !errors.Is(err, ErrNotArchive) {
+		t.Fatalf("unregistered scheme got error %v, want ErrNotArchive", err)
+	}
+
+	RegisterScheme(&testScheme{name: "XYZ", magic: "XYZ-1.0", key: 0x77})
+
+	archive, err := NewReader(out, int64(len(out.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := archive.Scheme().Name(); name != "XYZ" {
+		t.Errorf("Scheme().Name() = %s, want XYZ", name)
+	}
+	if info := archive.Info(); info.Scheme != "XYZ" || info.Key != 0x77 {
+		t.Errorf("Info() has scheme %s with key %x, want XYZ with key 77", info.Scheme, info.Key)
+	}
+	checkContents(t, archive, map[string]string{"custom.txt": "custom"})
+
+	// a later scheme for the same header wins, even over built-in ones
+	RegisterScheme(&testScheme{name: "XYZ v2", magic: "XYZ-1.0", key: 0x77})
+	RegisterScheme(&testScheme{name: "custom RPA-3.0", magic: "RPA-3.0"})
+
+	archive, err = NewReader(out, int64(len(out.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := archive.Scheme().Name(); name != "XYZ v2" {
+		t.Errorf("Scheme().Name() = %s, want XYZ v2", name)
+	}
+
+	rpa := buildArchive(t, RPAVersion3, 0, map[string]string{"a.txt": "a"}, 0)
+	archive, err = NewReader(rpa, int64(len(rpa.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := archive.Scheme().Name(); name != "custom RPA-3.0" {
+		t.Errorf("Scheme().Name() = %s, want custom RPA-3.0", name)
+	}
+}
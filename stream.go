@@ -0,0 +1,219 @@
+package renpyarchivetool
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// StreamOptions controls how archives are added to a TarWriter or ZipWriter.
+type StreamOptions struct {
+	// SanitizePaths rewrites unsafe names like "../x" instead of leaving the
+	// files out, see ExtractOptions.
+	SanitizePaths bool
+	// Filter selects the files added, all files if nil.
+	Filter *Filter
+}
+
+// streamFormat writes entries of a tar or zip stream.
+type streamFormat interface {
+	writeDir(name string, modTime time.Time) error
+	writeFile(name string, size int64, modTime time.Time, r io.Reader) error
+}
+
+// streamWriter adds the files of archives to a stream, adding each folder
+// once before the first file in it. Only the first file with a name is
+// added, as a stream can't replace entries.
+type streamWriter struct {
+	format streamFormat
+	dirs   map[string]bool
+	files  map[string]bool
+}
+
+// AddArchive adds the files of rp selected by opts. Files with unsafe names
+// and files whose name is already in the stream, from this or an earlier
+// archive, are left out and reported in an *ExtractError after the other
+// files have been added. Names already in the stream fail with fs.ErrExist.
+func (sw *streamWriter) AddArchive(rp *RenPyArchive, opts StreamOptions) (*ExtractSummary, error) {
+	if err := opts.Filter.Validate(); err != nil {
+		return &ExtractSummary{}, err
+	}
+
+	modTime := rp.ModTime()
+	summary := &ExtractSummary{}
+	var errs []error
+
+	for _, entry := range rp.Entries(WalkOptions{ByOffset: true, Filter: opts.Filter}) {
+		rel, err := safePath(entry.Name, opts.SanitizePaths)
+		if err != nil {
+			summary.Rejected++
+			errs = append(errs, &fs.PathError{Op: "extract", Path: entry.Name, Err: err})
+			continue
+		}
+		name := filepath.ToSlash(rel)
+
+		if sw.taken(name) {
+			summary.Skipped++
+			errs = append(errs, &fs.PathError{Op: "extract", Path: entry.Name, Err: fs.ErrExist})
+			continue
+		}
+
+		if err := sw.addDirs(path.Dir(name), modTime); err != nil {
+			return summary, err
+		}
+
+		file, err := rp.Open(entry.Name)
+		if err != nil {
+			return summary, err
+		}
+
+		// a broken stream can't be continued, so every error is fatal
+		if err := sw.format.writeFile(name, entry.Index.Length, modTime, file); err != nil {
+			return summary, &fs.PathError{Op: "extract", Path: entry.Name, Err: err}
+		}
+
+		sw.files[name] = true
+		summary.Files++
+		summary.Created++
+		summary.Bytes += entry.Index.Length
+	}
+
+	if len(errs) > 0 {
+		return summary, &ExtractError{Errors: errs}
+	}
+
+	return summary, nil
+}
+
+// taken reports whether name or one of its folders is already used by an
+// entry that would conflict with it.
+func (sw *streamWriter) taken(name string) bool {
+	if sw.files[name] || sw.dirs[name] {
+		return true
+	}
+
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if sw.files[dir] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (sw *streamWriter) addDirs(dir string, modTime time.Time) error {
+	if dir == "." || sw.dirs[dir] {
+		return nil
+	}
+
+	if err := sw.addDirs(path.Dir(dir), modTime); err != nil {
+		return err
+	}
+
+	if err := sw.format.writeDir(dir, modTime); err != nil {
+		return err
+	}
+	sw.dirs[dir] = true
+
+	return nil
+}
+
+// TarWriter writes the files of archives to a tar stream without touching
+// the disk. All entries get the modification time of their archive.
+type TarWriter struct {
+	streamWriter
+	tw *tar.Writer
+}
+
+// NewTarWriter creates a TarWriter writing to w.
+func NewTarWriter(w io.Writer) *TarWriter {
+	t := &TarWriter{tw: tar.NewWriter(w)}
+	t.streamWriter = streamWriter{format: t, dirs: make(map[string]bool), files: make(map[string]bool)}
+
+	return t
+}
+
+// Close finishes the tar stream, it doesn't close the underlying writer.
+func (t *TarWriter) Close() error {
+	return t.tw.Close()
+}
+
+func (t *TarWriter) writeDir(name string, modTime time.Time) error {
+	return t.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name + "/",
+		Mode:     0755,
+		ModTime:  modTime,
+	})
+}
+
+func (t *TarWriter) writeFile(name string, size int64, modTime time.Time, r io.Reader) error {
+	err := t.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  modTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(t.tw, r)
+
+	return err
+}
+
+// ZipWriter writes the files of archives to a zip stream without touching
+// the disk. All entries get the modification time of their archive.
+type ZipWriter struct {
+	streamWriter
+	zw *zip.Writer
+}
+
+// NewZipWriter creates a ZipWriter writing to w.
+func NewZipWriter(w io.Writer) *ZipWriter {
+	z := &ZipWriter{zw: zip.NewWriter(w)}
+	z.streamWriter = streamWriter{format: z, dirs: make(map[string]bool), files: make(map[string]bool)}
+
+	return z
+}
+
+// Close finishes the zip stream, it doesn't close the underlying writer.
+func (z *ZipWriter) Close() error {
+	return z.zw.Close()
+}
+
+func (z *ZipWriter) writeDir(name string, modTime time.Time) error {
+	header := &zip.FileHeader{
+		Name:     name + "/",
+		Modified: modTime,
+	}
+	header.SetMode(fs.ModeDir | 0755)
+
+	_, err := z.zw.CreateHeader(header)
+
+	return err
+}
+
+func (z *ZipWriter) writeFile(name string, _ int64, modTime time.Time, r io.Reader) error {
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modTime,
+	}
+	header.SetMode(0644)
+
+	w, err := z.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, r)
+
+	return err
+}
@@ -0,0 +1,144 @@
+package renpyarchivetool
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+)
+
+func TestStreamDuplicates(t *testing.T) {
+	first := openArchive(t, RPAVersion3, 0x1, map[string]string{
+		"a.txt":   "first a",
+		"dir/b":   "first b",
+		"file":    "first file",
+		"only.rp": "only",
+	}, 0)
+	second := openArchive(t, RPAVersion2, 0, map[string]string{
+		"a.txt":      "second a",
+		"dir/b":      "second b",
+		"dir":        "not a folder",
+		"file/child": "below a file",
+		"new.txt":    "new",
+	}, 0)
+
+	want := map[string]string{
+		"a.txt":   "first a",
+		"dir/b":   "first b",
+		"file":    "first file",
+		"only.rp": "only",
+		"new.txt": "new",
+	}
+
+	check := func(t *testing.T, stream interface {
+		AddArchive(*RenPyArchive, StreamOptions) (*ExtractSummary, error)
+	}) {
+		if _, err := stream.AddArchive(first, StreamOptions{}); err != nil {
+			t.Fatal(err)
+		}
+
+		summary, err := stream.AddArchive(second, StreamOptions{})
+
+		var extractErr *ExtractError
+		if !errors.As(err, &extractErr) || len(extractErr.Errors) != 4 {
+			t.Fatalf("got error %v, want an *ExtractError with 4 errors", err)
+		}
+		for _, err := range extractErr.Errors {
+			if !errors.Is(err, fs.ErrExist) {
+				t.Errorf("got error %v, want fs.ErrExist", err)
+			}
+		}
+
+		if summary.Files != 1 || summary.Skipped != 4 {
+			t.Errorf("summary %s", summary)
+		}
+	}
+
+	t.Run("tar", func(t *testing.T) {
+		var buf bytes.Buffer
+		tw := NewTarWriter(&buf)
+		check(t, tw)
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		got := make(map[string]string)
+		tr := tar.NewReader(&buf)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header.Typeflag != tar.TypeReg {
+				continue
+			}
+
+			if _, ok := got[header.Name]; ok {
+				t.Errorf("%s is in the stream twice", header.Name)
+			}
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got[header.Name] = string(data)
+		}
+
+		checkEntries(t, got, want)
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := NewZipWriter(&buf)
+		check(t, zw)
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := make(map[string]string)
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+
+			if _, ok := got[f.Name]; ok {
+				t.Errorf("%s is in the stream twice", f.Name)
+			}
+			r, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got[f.Name] = string(data)
+		}
+
+		checkEntries(t, got, want)
+	})
+}
+
+func checkEntries(t *testing.T, got map[string]string, want map[string]string) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Errorf("stream has %d files, want %d", len(got), len(want))
+	}
+	for name, data := range want {
+		if got[name] != data {
+			t.Errorf("%s = %q, want %q", name, got[name], data)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package renpyarchivetool
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// StopWalk can be returned by a WalkFunc to stop walking without Walk
+// returning an error.
+var StopWalk = errors.New("stop walk")
+
+// WalkFunc is called by Walk for each file in the archive.
+type WalkFunc func(name string, index *Index) error
+
+// WalkOptions controls which files Walk and Entries visit and in which order.
+type WalkOptions struct {
+	// Dir limits the walk to files below this directory of the archive.
+	Dir string
+	// ByOffset orders files by the offset of their data instead of by name,
+	// so reading them in order reads the archive sequentially.
+	ByOffset bool
+	// Filter selects the files visited, all files if nil.
+	Filter *Filter
+}
+
+// Entry is a file in the archive.
+type Entry struct {
+	Name  string
+	Index *Index
+}
+
+// Entries returns the files of the archive selected by opts, in order.
+func (rp *RenPyArchive) Entries(opts WalkOptions) []Entry {
+	dir := strings.Trim(opts.Dir, "/")
+	if dir == "." {
+		dir = ""
+	}
+	if dir != "" {
+		dir += "/"
+	}
+
+	entries := make([]Entry, 0, len(rp.indexes))
+	for name, index := range rp.indexes {
+		if !strings.HasPrefix(name, dir) || !opts.Filter.Match(name, index.Length) {
+			continue
+		}
+		entries = append(entries, Entry{Name: name, Index: index})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if opts.ByOffset && a.Index.Offset != b.Index.Offset {
+			return a.Index.Offset < b.Index.Offset
+		}
+		return a.Name < b.Name
+	})
+
+	return entries
+}
+
+// Walk calls fn for each file selected by opts, in order. Walk stops at the
+// first error returned by fn and returns it, unless it is StopWalk.
+func (rp *RenPyArchive) Walk(opts WalkOptions, fn WalkFunc) error {
+	for _, entry := range rp.Entries(opts) {
+		if err := fn(entry.Name, entry.Index); err != nil {
+			if err == StopWalk {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return nil
+}
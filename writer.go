@@ -0,0 +1,170 @@
+package renpyarchivetool
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Writer creates a Ren'Py archive. Files are written in the order they are
+// added; the index and header are written by Close.
+type Writer struct {
+	w       io.WriteSeeker
+	version RPAVersion
+	key     int64
+
+	start   int64
+	offset  int64
+	closed  bool
+	names   []string
+	indexes map[string]*Index
+}
+
+// NewWriter starts a new archive at the current position of w. key is used
+// to obfuscate the index, it must fit in 32 bits since Ren'Py reads it from a
+// fixed width field, and must be zero for RPA-2.0 archives.
+func NewWriter(w io.WriteSeeker, version RPAVersion, key int64) (*Writer, error) {
+	switch version {
+	case RPAVersion2:
+		if key != 0 {
+			return nil, fmt.Errorf("%sarchives do not support a key", version)
+		}
+	case RPAVersion3, RPAVersion32:
+		if key < 0 || key > math.MaxUint32 {
+			return nil, fmt.Errorf("key must be between 0 and 0xffffffff. was: 0x%x", key)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedVersion, version)
+	}
+
+	start, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	wr := &Writer{
+		w:       w,
+		version: version,
+		key:     key,
+		start:   start,
+		indexes: make(map[string]*Index),
+	}
+
+	// reserve room for the header, it is rewritten once the index offset is known
+	header := wr.header(0)
+	if _, err := io.WriteString(w, header); err != nil {
+		return nil, fmt.Errorf("failed to write header. %v", err)
+	}
+	wr.offset = int64(len(header))
+
+	return wr, nil
+}
+
+func (wr *Writer) header(indexOffset int64) string {
+	return formatHeader(wr.version, indexOffset, wr.key)
+}
+
+func formatHeader(version RPAVersion, indexOffset int64, key int64) string {
+	switch version {
+	case RPAVersion3:
+		return fmt.Sprintf("%s%016x %08x\n", RPA3Magic, indexOffset, key)
+	case RPAVersion32:
+		return fmt.Sprintf("%s%016x %08x %08x\n", RPA32Magic, indexOffset, 0, key)
+	}
+
+	return fmt.Sprintf("%s%016x\n", RPA2Magic, indexOffset)
+}
+
+// compressIndex returns the pickled and zlib compressed index.
+func compressIndex(names []string, indexes map[string]*Index, key int64) ([]byte, error) {
+	var buf bytes.Buffer
+	zlibWriter := zlib.NewWriter(&buf)
+	if _, err := zlibWriter.Write(pickleIndex(names, indexes, key)); err != nil {
+		return nil, err
+	}
+	if err := zlibWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// AddFile copies the contents of r into the archive under name.
+func (wr *Writer) AddFile(name string, r io.Reader) error {
+	return wr.AddFilePrefixed(name, r, 0)
+}
+
+// AddFilePrefixed is like AddFile, but stores up to prefixLength leading
+// bytes of the file in the index instead of the data section, the same way
+// Ren'Py can store the start of a file alongside its index entry.
+func (wr *Writer) AddFilePrefixed(name string, r io.Reader, prefixLength int) error {
+	if wr.closed {
+		return fmt.Errorf("archive writer is closed")
+	}
+
+	if _, ok := wr.indexes[name]; ok {
+		return fmt.Errorf("file %s already added to archive", name)
+	}
+
+	if prefixLength < 0 {
+		return fmt.Errorf("prefix length must not be negative. was: %d", prefixLength)
+	}
+
+	prefix := make([]byte, prefixLength)
+	numRead, err := io.ReadFull(r, prefix)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("failed to read prefix of file %s. %v", name, err)
+	}
+	prefix = prefix[:numRead]
+
+	n, err := io.Copy(wr.w, r)
+	// data written before a failure stays in the archive, later files have
+	// to start after it
+	wr.offset += n
+	if err != nil {
+		return fmt.Errorf("failed to write file %s. %v", name, err)
+	}
+
+	wr.names = append(wr.names, name)
+	wr.indexes[name] = NewIndex([]Segment{{
+		Offset: wr.offset - n,
+		Length: n,
+		Prefix: prefix,
+	}})
+
+	return nil
+}
+
+// Close writes the index and the final header. It does not close the
+// underlying writer.
+func (wr *Writer) Close() error {
+	if wr.closed {
+		return nil
+	}
+	wr.closed = true
+
+	index, err := compressIndex(wr.names, wr.indexes, wr.key)
+	if err != nil {
+		return err
+	}
+
+	indexOffset := wr.offset
+	if _, err := wr.w.Write(index); err != nil {
+		return fmt.Errorf("failed to write indexes. %v", err)
+	}
+	wr.offset += int64(len(index))
+
+	if _, err := wr.w.Seek(wr.start, io.SeekStart); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(wr.w, wr.header(indexOffset)); err != nil {
+		return fmt.Errorf("failed to write header. %v", err)
+	}
+
+	_, err = wr.w.Seek(wr.start+wr.offset, io.SeekStart)
+
+	return err
+}